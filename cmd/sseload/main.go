@@ -0,0 +1,241 @@
+// Command sseload opens many concurrent connections to an SSE endpoint served by sse.Hub
+// and reports connect time, message latency, drops and reconnects.
+//
+// message latency is computed from a unix millisecond timestamp embedded in the JSON data
+// of each message (field "time" by default, as sent by the example server), e.g.
+//
+//	go run ./sse/example/server/server.go
+//	go run ./cmd/sseload -url http://localhost:8080/sse -n 200 -d 30s -send http://localhost:8080/broadcast?content=load
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	"github.com/EilenC/ecommon/sse"
+)
+
+// errNotConnected the connection ended before the first event
+var errNotConnected = errors.New("not connected")
+
+// config command line flags
+type config struct {
+	url       string
+	n         int
+	duration  time.Duration
+	ramp      time.Duration
+	event     string
+	field     string
+	mode      string
+	reconnect time.Duration
+	send      string
+	rate      time.Duration
+	verbose   bool
+}
+
+func main() {
+	cfg := config{}
+	flag.StringVar(&cfg.url, "url", "http://localhost:8080/sse", "SSE endpoint")
+	flag.IntVar(&cfg.n, "n", 100, "number of concurrent connections")
+	flag.DurationVar(&cfg.duration, "d", 30*time.Second, "test duration")
+	flag.DurationVar(&cfg.ramp, "ramp", 0, "spread opening the connections over this time")
+	flag.StringVar(&cfg.event, "event", "customEvent", "event to measure (raw mode: empty measures every event except ping)")
+	flag.StringVar(&cfg.field, "field", "time", "JSON field of the message data holding the unix millisecond send time")
+	flag.StringVar(&cfg.mode, "mode", "client", "client: sse.Client per connection, raw: net/http and sse.Decoder (exact time to first event)")
+	flag.DurationVar(&cfg.reconnect, "reconnect", time.Second, "delay before reconnecting a dropped connection")
+	flag.StringVar(&cfg.send, "send", "", "URL requested every -rate to trigger messages, e.g. http://localhost:8080/broadcast?content=load")
+	flag.DurationVar(&cfg.rate, "rate", time.Second, "interval of -send requests")
+	flag.BoolVar(&cfg.verbose, "v", false, "keep the logs of sse.Client")
+	flag.Parse()
+	if cfg.n <= 0 || (cfg.mode != "client" && cfg.mode != "raw") || (cfg.mode == "client" && cfg.event == "") {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if !cfg.verbose {
+		log.SetOutput(io.Discard)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.duration)
+	defer cancel()
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		cancel()
+	}()
+
+	st := &stats{}
+	start := time.Now()
+	run(ctx, cfg, st)
+	st.report(os.Stdout, cfg.n, time.Since(start))
+}
+
+// run open cfg.n connections and keep them until ctx is done
+func run(ctx context.Context, cfg config, st *stats) {
+	var wg sync.WaitGroup
+	if cfg.send != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			publish(ctx, cfg.send, cfg.rate)
+		}()
+	}
+	for i := 0; i < cfg.n; i++ {
+		if cfg.ramp > 0 && i > 0 {
+			select {
+			case <-time.After(cfg.ramp / time.Duration(cfg.n)):
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if cfg.mode == "raw" {
+				runRaw(ctx, cfg, st)
+				return
+			}
+			runClient(ctx, cfg, st)
+		}()
+	}
+	wg.Wait()
+}
+
+// runClient one connection using sse.Client, connect time is measured until OnConnection
+func runClient(ctx context.Context, cfg config, st *stats) {
+	var (
+		mu        sync.Mutex
+		connected bool
+	)
+	start := time.Now()
+	client := sse.NewClient(cfg.url, http.MethodGet, cfg.reconnect)
+	client.OnConnection(func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if connected {
+			st.reconnect()
+			return
+		}
+		connected = true
+		st.connected(time.Since(start))
+	})
+	client.OnDisconnect(func(string) {
+		if ctx.Err() == nil {
+			st.drop()
+		}
+	})
+	client.SubscribeEvent(cfg.event, func(message *sse.Message) {
+		st.message(latency(message.Data, cfg.field, time.Now()))
+	})
+	go func() {
+		<-ctx.Done()
+		client.Stop()
+	}()
+	client.Start()
+	mu.Lock()
+	if !connected {
+		st.failure()
+	}
+	mu.Unlock()
+}
+
+// runRaw one connection using net/http and sse.Decoder, connect time is measured until the first event
+func runRaw(ctx context.Context, cfg config, st *stats) {
+	opened := false
+	for ctx.Err() == nil {
+		err := stream(ctx, cfg, st, opened)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == errNotConnected {
+			if !opened {
+				st.failure()
+			}
+		} else {
+			opened = true
+			st.drop()
+		}
+		select {
+		case <-time.After(cfg.reconnect):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// stream read one connection until it ends, errNotConnected if no event was received
+func stream(ctx context.Context, cfg config, st *stats, reconnect bool) error {
+	start := time.Now()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cfg.url, nil)
+	if err != nil {
+		return errNotConnected
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return errNotConnected
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return errNotConnected
+	}
+	decoder := sse.NewDecoder(resp.Body)
+	first := true
+	for {
+		message, err := decoder.Decode()
+		if err != nil {
+			if first {
+				return errNotConnected
+			}
+			return err
+		}
+		now := time.Now()
+		if first {
+			first = false
+			if reconnect {
+				st.reconnect()
+			} else {
+				st.connected(now.Sub(start))
+			}
+		}
+		if cfg.event == "" && message.Event == "ping" || cfg.event != "" && message.Event != cfg.event {
+			continue
+		}
+		st.message(latency(message.Data, cfg.field, now))
+	}
+}
+
+// publish request url every rate to trigger messages
+func publish(ctx context.Context, url string, rate time.Duration) {
+	ticker := time.NewTicker(rate)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			continue
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}
+}
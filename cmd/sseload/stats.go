@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// stats results collected by every connection
+type stats struct {
+	mu         sync.Mutex
+	connects   []time.Duration //连接耗时
+	latencies  []time.Duration //消息延迟 (基于消息中的时间戳)
+	messages   int
+	untimed    int //无法解析时间戳的消息
+	drops      int //连接中断
+	reconnects int
+	failures   int //连接失败
+}
+
+func (s *stats) connected(d time.Duration) {
+	s.mu.Lock()
+	s.connects = append(s.connects, d)
+	s.mu.Unlock()
+}
+
+func (s *stats) message(latency time.Duration, timed bool) {
+	s.mu.Lock()
+	s.messages++
+	if timed {
+		s.latencies = append(s.latencies, latency)
+	} else {
+		s.untimed++
+	}
+	s.mu.Unlock()
+}
+
+func (s *stats) drop() {
+	s.mu.Lock()
+	s.drops++
+	s.mu.Unlock()
+}
+
+func (s *stats) reconnect() {
+	s.mu.Lock()
+	s.reconnects++
+	s.mu.Unlock()
+}
+
+func (s *stats) failure() {
+	s.mu.Lock()
+	s.failures++
+	s.mu.Unlock()
+}
+
+// latency of a message whose data is a JSON object with a unix millisecond timestamp in field
+func latency(data, field string, now time.Time) (time.Duration, bool) {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(data), &object); err != nil {
+		return 0, false
+	}
+	var ms float64
+	if err := json.Unmarshal(object[field], &ms); err != nil || ms <= 0 {
+		return 0, false
+	}
+	sent := time.Unix(0, int64(ms*float64(time.Millisecond)))
+	return now.Sub(sent), true
+}
+
+// percentile nearest-rank percentile p (0-100) of sorted durations
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
+// report write the summary of the run
+func (s *stats) report(w io.Writer, connections int, elapsed time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(s.messages) / elapsed.Seconds()
+	}
+	fmt.Fprintf(w, "connections: %d opened %d failed, duration %v\n", len(s.connects), s.failures, elapsed.Round(time.Millisecond))
+	fmt.Fprintf(w, "messages:    %d (%.1f/s), %d without timestamp\n", s.messages, rate, s.untimed)
+	fmt.Fprintf(w, "drops:       %d, reconnects %d (target %d connections)\n", s.drops, s.reconnects, connections)
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "%-10s %10s %10s %10s %10s %10s %10s\n", "", "min", "p50", "p90", "p99", "max", "count")
+	writeDurations(w, "connect", s.connects)
+	writeDurations(w, "latency", s.latencies)
+}
+
+func writeDurations(w io.Writer, name string, durations []time.Duration) {
+	sorted := append([]time.Duration{}, durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	cols := []string{name}
+	for _, p := range []float64{0, 50, 90, 99, 100} {
+		cols = append(cols, percentile(sorted, p).Round(time.Microsecond).String())
+	}
+	fmt.Fprintf(w, "%-10s %10s %10s %10s %10s %10s %10d\n", cols[0], cols[1], cols[2], cols[3], cols[4], cols[5], len(sorted))
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	sorted := []time.Duration{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	tests := []struct {
+		p    float64
+		want time.Duration
+	}{
+		{p: 0, want: 1},
+		{p: 50, want: 5},
+		{p: 90, want: 9},
+		{p: 99, want: 10},
+		{p: 100, want: 10},
+	}
+	for _, tt := range tests {
+		if got := percentile(sorted, tt.p); got != tt.want {
+			t.Errorf("percentile(%v) = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+	if got := percentile(nil, 50); got != 0 {
+		t.Errorf("percentile(nil) = %v, want 0", got)
+	}
+}
+
+func TestLatency(t *testing.T) {
+	now := time.UnixMilli(10_000)
+	tests := []struct {
+		name      string
+		data      string
+		want      time.Duration
+		wantTimed bool
+	}{
+		{name: "example server", data: `{"msg":"load","time":9750}` + "\n", want: 250 * time.Millisecond, wantTimed: true},
+		{name: "missing field", data: `{"msg":"load"}`},
+		{name: "not json", data: "loop 1700000000"},
+		{name: "string field", data: `{"time":"9750"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, timed := latency(tt.data, "time", now)
+			if got != tt.want || timed != tt.wantTimed {
+				t.Fatalf("latency() = %v, %v, want %v, %v", got, timed, tt.want, tt.wantTimed)
+			}
+		})
+	}
+}
+
+func TestStats_report(t *testing.T) {
+	st := &stats{}
+	st.connected(10 * time.Millisecond)
+	st.connected(20 * time.Millisecond)
+	st.message(5*time.Millisecond, true)
+	st.message(0, false)
+	st.drop()
+	st.reconnect()
+	st.failure()
+
+	var out bytes.Buffer
+	st.report(&out, 3, 2*time.Second)
+	for _, want := range []string{
+		"connections: 2 opened 1 failed",
+		"messages:    2 (1.0/s), 1 without timestamp",
+		"drops:       1, reconnects 1 (target 3 connections)",
+		"connect",
+		"latency",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("report missing %q:\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package sse
+
+import (
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Backoff reconnect scheduling of Client, the base delay is the last retry field sent by the server
+// or the reconnectDelay of NewClient
+type Backoff struct {
+	Multiplier  float64       //连续失败时间隔的倍数,<= 1 为固定间隔
+	Max         time.Duration //间隔上限,为 0 不限制
+	Jitter      float64       //随机抖动比例 (0~1),如 0.2 为 ±20%,超出范围时取边界值
+	MaxAttempts int           //连续失败的最大重连次数,为 0 不限制
+}
+
+// SetBackoff set the reconnect scheduling, the default is a fixed delay without limit
+func (c *Client) SetBackoff(b Backoff) {
+	if b.Jitter < 0 {
+		b.Jitter = 0
+	} else if b.Jitter > 1 {
+		b.Jitter = 1
+	}
+	c.retryMu.Lock()
+	defer c.retryMu.Unlock()
+	c.backoff = b
+}
+
+// trackRetry remember the reconnection time (milliseconds) sent in the retry field
+func (c *Client) trackRetry(message *Message) {
+	if message.Retry == "" {
+		return
+	}
+	ms, err := strconv.ParseInt(message.Retry, 10, 64)
+	if err != nil || ms < 0 {
+		return
+	}
+	c.retryMu.Lock()
+	c.serverRetry = time.Duration(ms) * time.Millisecond
+	c.retryMu.Unlock()
+}
+
+// maxDelay the longest delay of nextDelay, float64(math.MaxInt64) rounds up to 2^63
+const maxDelay = float64(math.MaxInt64)
+
+// nextDelay the delay before the next attempt after failures consecutive failed attempts,
+// ok is false when Backoff.MaxAttempts is exceeded
+func (c *Client) nextDelay(failures int) (delay time.Duration, ok bool) {
+	c.retryMu.Lock()
+	defer c.retryMu.Unlock()
+	b := c.backoff
+	if b.MaxAttempts > 0 && failures > b.MaxAttempts {
+		return 0, false
+	}
+	base := c.reconnectDelay
+	if c.serverRetry > 0 {
+		base = c.serverRetry
+	}
+	d := float64(base)
+	if b.Multiplier > 1 && failures > 1 {
+		d *= math.Pow(b.Multiplier, float64(failures-1))
+	}
+	if b.Max > 0 && d > float64(b.Max) {
+		d = float64(b.Max)
+	}
+	// without Max the delay grows past what a Duration holds (or to +Inf) after enough failures
+	if d > maxDelay {
+		d = maxDelay
+	}
+	if b.Jitter > 0 {
+		d += d * b.Jitter * (2*rand.Float64() - 1)
+	}
+	if d >= maxDelay {
+		return time.Duration(math.MaxInt64), true
+	}
+	if d < 0 {
+		d = 0
+	}
+	return time.Duration(d), true
+}
+
+// permanentStatus responses after which the client stops reconnecting:
+// 204 (the server asks the client to stop) and 4xx except 408 and 429
+func permanentStatus(code int) bool {
+	if code == http.StatusNoContent {
+		return true
+	}
+	return code >= 400 && code < 500 && code != http.StatusRequestTimeout && code != http.StatusTooManyRequests
+}
+
+// retryAfter the delay of a Retry-After header (seconds or HTTP date), 0 if absent
+func retryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(header); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
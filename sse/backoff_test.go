@@ -0,0 +1,179 @@
+package sse
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClient_nextDelay(t *testing.T) {
+	tests := []struct {
+		name     string
+		backoff  Backoff
+		retry    string
+		failures int
+		want     time.Duration
+		wantOK   bool
+	}{
+		{name: "fixed", failures: 5, want: time.Second, wantOK: true},
+		{name: "first failure", backoff: Backoff{Multiplier: 2}, failures: 1, want: time.Second, wantOK: true},
+		{name: "exponential", backoff: Backoff{Multiplier: 2}, failures: 4, want: 8 * time.Second, wantOK: true},
+		{name: "capped", backoff: Backoff{Multiplier: 2, Max: 5 * time.Second}, failures: 10, want: 5 * time.Second, wantOK: true},
+		{name: "server retry", backoff: Backoff{Multiplier: 2}, retry: "100", failures: 2, want: 200 * time.Millisecond, wantOK: true},
+		{name: "invalid server retry", retry: "soon", failures: 1, want: time.Second, wantOK: true},
+		{name: "max attempts", backoff: Backoff{MaxAttempts: 3}, failures: 3, want: time.Second, wantOK: true},
+		{name: "max attempts exceeded", backoff: Backoff{MaxAttempts: 3}, failures: 4, wantOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewClient("http://localhost/events", http.MethodGet, time.Second)
+			client.SetBackoff(tt.backoff)
+			client.trackRetry(&Message{Retry: tt.retry})
+			got, ok := client.nextDelay(tt.failures)
+			if got != tt.want || ok != tt.wantOK {
+				t.Fatalf("nextDelay(%d) = %v, %v, want %v, %v", tt.failures, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestClient_nextDelayJitter(t *testing.T) {
+	client := NewClient("http://localhost/events", http.MethodGet, time.Second)
+	client.SetBackoff(Backoff{Jitter: 0.2})
+	for i := 0; i < 100; i++ {
+		got, _ := client.nextDelay(1)
+		if got < 800*time.Millisecond || got > 1200*time.Millisecond {
+			t.Fatalf("nextDelay() = %v, want within ±20%% of 1s", got)
+		}
+	}
+}
+
+func TestClient_nextDelayUnbounded(t *testing.T) {
+	client := NewClient("http://localhost/events", http.MethodGet, 3*time.Second)
+	client.SetBackoff(Backoff{Multiplier: 2, Jitter: 0.5})
+	for _, failures := range []int{30, 33, 64, 1100, math.MaxInt32} {
+		if got, _ := client.nextDelay(failures); got < 200000*time.Hour {
+			t.Fatalf("nextDelay(%d) = %v, want a long positive delay", failures, got)
+		}
+	}
+	client.SetBackoff(Backoff{Multiplier: 2})
+	if got, _ := client.nextDelay(1100); got != time.Duration(math.MaxInt64) {
+		t.Fatalf("nextDelay(1100) = %v, want the longest Duration", got)
+	}
+}
+
+func TestClient_SetBackoffJitterRange(t *testing.T) {
+	client := NewClient("http://localhost/events", http.MethodGet, time.Second)
+	client.SetBackoff(Backoff{Jitter: -1})
+	if got, _ := client.nextDelay(1); got != time.Second {
+		t.Fatalf("nextDelay() with negative jitter = %v, want 1s", got)
+	}
+	client.SetBackoff(Backoff{Jitter: 5})
+	for i := 0; i < 100; i++ {
+		if got, _ := client.nextDelay(1); got < 0 || got > 2*time.Second {
+			t.Fatalf("nextDelay() = %v, want within ±100%% of 1s", got)
+		}
+	}
+}
+
+func TestPermanentStatus(t *testing.T) {
+	for code, want := range map[int]bool{
+		http.StatusNoContent:           true,
+		http.StatusUnauthorized:        true,
+		http.StatusNotFound:            true,
+		http.StatusRequestTimeout:      false,
+		http.StatusTooManyRequests:     false,
+		http.StatusBadGateway:          false,
+		http.StatusServiceUnavailable:  false,
+		http.StatusInternalServerError: false,
+	} {
+		if got := permanentStatus(code); got != want {
+			t.Errorf("permanentStatus(%d) = %v, want %v", code, got, want)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	if got := retryAfter("2"); got != 2*time.Second {
+		t.Errorf("retryAfter(2) = %v", got)
+	}
+	if got := retryAfter(""); got != 0 {
+		t.Errorf("retryAfter() = %v", got)
+	}
+	if got := retryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)); got < 58*time.Second || got > time.Minute {
+		t.Errorf("retryAfter(date) = %v", got)
+	}
+}
+
+// startClient run client until it stops by itself
+func startClient(t *testing.T, client *Client) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		client.Start()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		client.Stop()
+		t.Fatal("client did not stop reconnecting")
+	}
+}
+
+func TestClient_connectStopsReconnecting(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		backoff      Backoff
+		wantRequests int32
+	}{
+		{name: "no content", status: http.StatusNoContent, wantRequests: 1},
+		{name: "unauthorized", status: http.StatusUnauthorized, wantRequests: 1},
+		{name: "not found", status: http.StatusNotFound, wantRequests: 1},
+		{name: "max attempts", status: http.StatusServiceUnavailable, backoff: Backoff{Multiplier: 2, MaxAttempts: 2}, wantRequests: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&requests, 1)
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+			client := NewClient(server.URL, http.MethodGet, time.Millisecond)
+			client.SetBackoff(tt.backoff)
+			startClient(t, client)
+			if got := atomic.LoadInt32(&requests); got != tt.wantRequests {
+				t.Fatalf("requests = %d, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestClient_connectHonoursRetryField(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", "text/event-stream")
+		// the stream ends right away, the client reconnects after the retry field
+		fmt.Fprintf(w, "retry: 10\nid: %d\ndata: x\n\n", n)
+	}))
+	defer server.Close()
+
+	// without the retry field the client would wait an hour
+	client := NewClient(server.URL, http.MethodGet, time.Hour)
+	go client.Start()
+	defer client.Stop()
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(&requests) < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("requests = %d, want reconnects after 10ms", atomic.LoadInt32(&requests))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -1,16 +1,170 @@
 package sse
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"os"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
-// SubscribeEvent Subscribe to callbacks for events
+// SubscribeEvent Subscribe to callbacks for events, replacing the callbacks of eventName.
+// Use Subscribe to add several callbacks to an event
 func (c *Client) SubscribeEvent(eventName string, callback EventCallback) {
-	c.eventCallbacks[eventName] = callback
+	c.eventCallbacks.replace(&subscription{pattern: eventName, callback: callback})
+}
+
+// HandleRequest Subscribe to request events sent by Hub.Request,
+// the result of handler is POSTed back to the reply url as a Reply.
+// The IDs of request events are correlation IDs, they do not change LastEventID
+func (c *Client) HandleRequest(eventName string, handler func(message *Message) (string, error)) {
+	callback := func(message *Message) {
+		reply := &Reply{ID: message.ID}
+		data, err := handler(message)
+		if err != nil {
+			reply.Error = err.Error()
+		} else {
+			reply.Data = data
+		}
+		if err = c.postReply(reply); err != nil {
+			log.Printf("reply request %s fail:%+v\n", message.ID, err)
+		}
+	}
+	c.eventCallbacks.replace(&subscription{pattern: eventName, callback: callback, request: true})
+}
+
+// SetReplyURL url the replies of HandleRequest are POSTed to (Hub.RegisterReply), default is the server url,
+// which Hub.RegisterBlock and Hub.Handler also accept replies on
+func (c *Client) SetReplyURL(url string) {
+	c.replyURL = url
+}
+
+// postReply POST reply to the reply url as JSON
+func (c *Client) postReply(reply *Reply) error {
+	b, err := json.Marshal(reply)
+	if err != nil {
+		return err
+	}
+	url := c.replyURL
+	if url == "" {
+		url = c.URL()
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	if err = c.applyHeaders(req); err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(ReplyHeader, "1")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("http status code error %d", resp.StatusCode)
+	}
+	if isEventStream(resp.Header.Get("Content-Type")) {
+		return fmt.Errorf("reply url %s serves an event stream, not Hub.RegisterReply", url)
+	}
+	return nil
+}
+
+// LastEventID the ID of the last message received, sent as the Last-Event-ID header when connecting
+func (c *Client) LastEventID() string {
+	c.idMu.Lock()
+	defer c.idMu.Unlock()
+	return c.lastEventID
+}
+
+// SetLastEventID resume from id on the next connection
+func (c *Client) SetLastEventID(id string) {
+	c.idMu.Lock()
+	c.lastEventID = id
+	c.idMu.Unlock()
+	c.saveLastEventID()
+}
+
+// lastEventIDInterval how often a changed last event ID is written to the file of PersistLastEventID
+const lastEventIDInterval = time.Second
+
+// PersistLastEventID keep the last event ID in path so resumption also works across restarts,
+// an ID already stored in path is loaded. Received IDs are written at most once per
+// lastEventIDInterval and when the client stops
+func (c *Client) PersistLastEventID(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	c.idMu.Lock()
+	defer c.idMu.Unlock()
+	c.lastEventIDFile = path
+	if id := strings.TrimSpace(string(b)); id != "" {
+		c.lastEventID = id
+	}
+	return nil
+}
+
+// trackEventID remember the ID of message
+func (c *Client) trackEventID(message *Message) {
+	// the Decoder does not tell an empty id field from a missing one, only non-empty IDs are kept
+	if message.ID == "" {
+		return
+	}
+	c.idMu.Lock()
+	defer c.idMu.Unlock()
+	if c.lastEventID == message.ID {
+		return
+	}
+	c.lastEventID = message.ID
+	// the file is written off the read goroutine, one write covers the IDs of an interval
+	if c.lastEventIDFile != "" && !c.idPending {
+		c.idPending = true
+		time.AfterFunc(lastEventIDInterval, c.saveLastEventID)
+	}
+}
+
+// flushLastEventID write the ID tracked since the last write, if any
+func (c *Client) flushLastEventID() {
+	c.idMu.Lock()
+	pending := c.idPending
+	c.idMu.Unlock()
+	if pending {
+		c.saveLastEventID()
+	}
+}
+
+// saveLastEventID write the current lastEventID to lastEventIDFile
+func (c *Client) saveLastEventID() {
+	c.idFileMu.Lock()
+	defer c.idFileMu.Unlock()
+	c.idMu.Lock()
+	id, path := c.lastEventID, c.lastEventIDFile
+	c.idPending = false
+	c.idMu.Unlock()
+	if path == "" {
+		return
+	}
+	// write and rename so a crash never leaves a partial ID
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(id), 0o644); err != nil {
+		log.Printf("save last event id fail:%+v\n", err)
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		log.Printf("save last event id fail:%+v\n", err)
+	}
 }
 
 func NewClient(url, method string, reconnectDelay time.Duration) *Client {
@@ -20,10 +174,11 @@ func NewClient(url, method string, reconnectDelay time.Duration) *Client {
 	return &Client{
 		url:            url,
 		method:         method,
-		eventCallbacks: map[string]EventCallback{},
+		eventCallbacks: newSubscriptions(),
+		header:         http.Header{},
 		client:         &http.Client{},
 		reconnectDelay: reconnectDelay,
-		stopSignal:     make(chan struct{}, 1),
+		stopSignal:     make(chan error, 1),
 		exitSignal:     make(chan struct{}),
 	}
 }
@@ -46,68 +201,163 @@ func (c *Client) OnExit(handler func()) {
 
 // Stop client stop connect
 func (c *Client) Stop() {
-	c.stopOnce.Do(func() {
-		close(c.exitSignal)
-	})
+	c.fail(nil)
 }
 
 // connect client connect to server
 func (c *Client) connect() {
-	ticker := time.NewTicker(c.reconnectDelay)
-	defer ticker.Stop()
-	for {
+	// abort a pending request once the client stops
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	defer c.closeDispatch()
+	if c.queue != nil {
+		go c.runQueue()
+	}
+	go func() {
+		<-c.exitSignal
+		cancel()
+	}()
+	failures := 0 //连续失败次数,连接成功后清零
+	var (
+		lastErr    error //上一次失败的原因
+		lastStatus int   //上一次响应的状态码
+	)
+	for attempts := 0; ; attempts++ {
 		select {
 		case <-c.exitSignal:
 			return
 		default:
 		}
-		req, err := http.NewRequest(c.method, c.url, nil)
+		if attempts > 0 {
+			atomic.AddUint64(&c.counters.reconnects, 1)
+		}
+		c.setState(StateChange{State: Connecting, Attempt: failures + 1, Err: lastErr, StatusCode: lastStatus})
+		url := c.URL()
+		req, err := c.streamRequest(url)
 		if err != nil {
 			log.Printf("create server connect fail:%+v\n", err)
+			c.fail(err)
 			return
 		}
-		resp, err := c.client.Do(req)
+		if err = c.applyHeaders(req); err != nil {
+			log.Printf("connecting to SSE %s server:%+v\n", url, err)
+			lastErr, lastStatus = err, 0
+			failures++
+			if !c.waitReconnect(failures, 0, err) {
+				return
+			}
+			continue
+		}
+		resp, err := c.client.Do(req.WithContext(ctx))
 		if err != nil {
-			log.Printf("connecting to SSE %s server:%+v\n", c.url, err)
-			log.Printf("reconnecting in %v...\n", c.reconnectDelay)
-			if !c.waitReconnect(ticker.C) {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("connecting to SSE %s server:%+v\n", url, err)
+			lastErr, lastStatus = err, 0
+			failures++
+			c.endpointFailed()
+			if !c.waitReconnect(failures, 0, err) {
 				return
 			}
 			continue
 		}
+		lastStatus = resp.StatusCode
 		if resp.StatusCode != http.StatusOK {
 			_ = resp.Body.Close()
 			log.Printf("http status code error %d \n", resp.StatusCode)
-			log.Printf("reconnecting in %v...\n", c.reconnectDelay)
-			if !c.waitReconnect(ticker.C) {
+			statusErr := &StatusError{Code: resp.StatusCode}
+			lastErr = statusErr
+			if permanentStatus(resp.StatusCode) {
+				log.Printf("stop reconnecting to SSE %s server after http status %d\n", url, resp.StatusCode)
+				c.fail(statusErr)
+				return
+			}
+			failures++
+			c.endpointFailed()
+			if !c.waitReconnect(failures, retryAfter(resp.Header.Get("Retry-After")), statusErr) {
 				return
 			}
 			continue
 		}
+		if mediaType := resp.Header.Get("Content-Type"); !isEventStream(mediaType) {
+			_ = resp.Body.Close()
+			log.Printf("stop reconnecting to SSE %s server after content type %q\n", url, mediaType)
+			c.fail(&ContentTypeError{ContentType: mediaType})
+			return
+		}
+		c.setState(StateChange{State: Open, Attempt: failures + 1, StatusCode: resp.StatusCode})
+		failures = 0
+		failback := make(chan struct{}, 1)
+		done := make(chan struct{})
+		if c.failover != nil && c.failover.opened() && c.failover.ProbeInterval > 0 {
+			go c.probe(done, failback)
+		}
 		if c.connectionHandler != nil {
 			go c.connectionHandler()
 		}
-		go c.listenEvents(resp.Body)
+		var body io.ReadCloser = &countingReader{ReadCloser: resp.Body, counters: &c.counters}
+		if c.idleTimeout > 0 {
+			body = newIdleReader(body, c.idleTimeout)
+		}
+		listening := make(chan struct{})
+		go func() {
+			c.listenEvents(body)
+			close(listening)
+		}()
 		select {
-		case <-c.stopSignal:
+		case err = <-c.stopSignal:
+		case <-failback:
+			_ = body.Close()
+			<-listening
+			// drop the read error caused by closing the body
+			select {
+			case <-c.stopSignal:
+			default:
+			}
+			err = ErrFailback
 		case <-c.exitSignal:
-			_ = resp.Body.Close()
+			close(done)
+			_ = body.Close()
+			// the listener must be gone before the event channels are closed
+			<-listening
 			return
 		}
+		close(done)
 
 		if c.disconnectHandler != nil {
-			go c.disconnectHandler("client stopped")
+			go c.disconnectHandler(disconnectReason(err))
 		}
-		log.Printf("reconnecting in %v...\n", c.reconnectDelay)
-		if !c.waitReconnect(ticker.C) {
+		lastErr = err
+		if errors.Is(err, ErrFailback) {
+			log.Printf("SSE endpoint %s is back, switching to it\n", c.failover.preferred())
+			continue
+		}
+		failures++
+		c.endpointFailed()
+		if !c.waitReconnect(failures, 0, err) {
 			return
 		}
 	}
 }
 
-func (c *Client) waitReconnect(ticker <-chan time.Time) bool {
+// waitReconnect wait before the next attempt, at least hint (e.g. Retry-After),
+// false when the client stopped or Backoff.MaxAttempts is exceeded, cause is the error of the failed attempt
+func (c *Client) waitReconnect(failures int, hint time.Duration, cause error) bool {
+	delay, ok := c.nextDelay(failures)
+	if !ok {
+		log.Printf("stop reconnecting to SSE %s server after %d attempts\n", c.URL(), failures)
+		c.fail(fmt.Errorf("%w (%d attempts): %v", ErrMaxAttempts, failures, cause))
+		return false
+	}
+	if delay < hint {
+		delay = hint
+	}
+	log.Printf("reconnecting in %v...\n", delay)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
 	select {
-	case <-ticker:
+	case <-timer.C:
 		return true
 	case <-c.exitSignal:
 		return false
@@ -128,24 +378,28 @@ func (c *Client) listenEvents(body io.ReadCloser) {
 				log.Println("reading from sse server:", err)
 			}
 			select {
-			case c.stopSignal <- struct{}{}:
+			case c.stopSignal <- err:
 			default:
 			}
 			return
 		}
 
-		// call the callback function of the subscription
-		if callback, ok := c.eventCallbacks[message.Event]; ok {
-			go callback(message)
+		atomic.AddUint64(&c.counters.events, 1)
+		// a correlation ID of Hub.Request is not retained, resuming from it would replay nothing
+		if !c.eventCallbacks.request(message.Event) {
+			c.trackEventID(message)
+		}
+		c.trackRetry(message)
+		if c.messageHandler != nil {
+			c.messageHandler(message)
+		}
+		if !c.dispatch(message) {
+			return
 		}
 	}
 }
 
+// Start connect and block until the client stops, see Run for the reason it stopped
 func (c *Client) Start() {
-	go c.connect()
-	// waiting for interrupt signal
-	<-c.exitSignal
-	if c.exitHandler != nil {
-		c.exitHandler()
-	}
+	_ = c.Run(context.Background())
 }
@@ -2,10 +2,14 @@ package sse
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -83,13 +87,13 @@ func TestClient_SubscribeEvent(t *testing.T) {
 	eventName := "test-event"
 	client.SubscribeEvent(eventName, callback)
 
-	if client.eventCallbacks[eventName] == nil {
+	if len(client.eventCallbacks.handlers(eventName)) == 0 {
 		t.Error("SubscribeEvent() callback was not registered")
 		return
 	}
 
 	// Test callback can be called
-	client.eventCallbacks[eventName](&Message{Event: eventName})
+	client.eventCallbacks.handlers(eventName)[0](&Message{Event: eventName})
 	if !callbackCalled {
 		t.Error("SubscribeEvent() callback was not called")
 	}
@@ -110,7 +114,7 @@ func TestClient_SubscribeMultipleEvents(t *testing.T) {
 	})
 
 	// Call event1
-	if cb, ok := client.eventCallbacks["event1"]; ok {
+	for _, cb := range client.eventCallbacks.handlers("event1") {
 		cb(&Message{Event: "event1"})
 	}
 
@@ -122,7 +126,7 @@ func TestClient_SubscribeMultipleEvents(t *testing.T) {
 	}
 
 	// Call event2
-	if cb, ok := client.eventCallbacks["event2"]; ok {
+	for _, cb := range client.eventCallbacks.handlers("event2") {
 		cb(&Message{Event: "event2"})
 	}
 
@@ -453,8 +457,8 @@ func TestClient_connectSuccessAndListenEvents(t *testing.T) {
 	}
 	select {
 	case got := <-disconnected:
-		if got != "client stopped" {
-			t.Fatalf("disconnect err = %q, want client stopped", got)
+		if got != "stream closed by server" {
+			t.Fatalf("disconnect err = %q, want stream closed by server", got)
 		}
 	case <-time.After(time.Second):
 		t.Fatal("connect() did not call disconnect handler")
@@ -512,7 +516,7 @@ func TestClient_AllHandlers(t *testing.T) {
 	if client.exitHandler == nil {
 		t.Error("exitHandler not set")
 	}
-	if client.eventCallbacks["test"] == nil {
+	if len(client.eventCallbacks.handlers("test")) == 0 {
 		t.Error("event callback not set")
 	}
 
@@ -520,7 +524,7 @@ func TestClient_AllHandlers(t *testing.T) {
 	client.connectionHandler()
 	client.disconnectHandler("error")
 	client.exitHandler()
-	client.eventCallbacks["test"](&Message{})
+	client.eventCallbacks.handlers("test")[0](&Message{})
 
 	if !connectionCalled || !disconnectCalled || !exitCalled || !eventCalled {
 		t.Error("Not all handlers were called successfully")
@@ -544,3 +548,108 @@ func (r errorReadCloser) Read([]byte) (int, error) {
 func (r errorReadCloser) Close() error {
 	return nil
 }
+
+func TestClient_LastEventID(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		headers []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		headers = append(headers, r.Header.Get("Last-Event-ID"))
+		n := len(headers)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/event-stream")
+		if n == 1 {
+			// the first connection ends after two messages
+			fmt.Fprint(w, "id: 1\nevent: e\ndata: a\n\nid: 2\nevent: e\ndata: b\n\n")
+			return
+		}
+		fmt.Fprint(w, "id: 3\nevent: e\ndata: c\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "last-event-id")
+	client := NewClient(server.URL, http.MethodGet, 10*time.Millisecond)
+	if err := client.PersistLastEventID(path); err != nil {
+		t.Fatalf("PersistLastEventID() error = %v", err)
+	}
+	received := make(chan string, 10)
+	client.SubscribeEvent("e", func(message *Message) {
+		received <- message.ID
+	})
+	go client.Start()
+	for _, want := range []string{"1", "2", "3"} {
+		select {
+		case <-received:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("message %s not received", want)
+		}
+	}
+	mu.Lock()
+	got := append([]string{}, headers...)
+	mu.Unlock()
+	if len(got) != 2 || got[0] != "" || got[1] != "2" {
+		t.Fatalf("Last-Event-ID headers = %q, want [\"\" \"2\"]", got)
+	}
+	// written after an interval while running
+	waitFor(t, "the persisted id", func() bool {
+		b, _ := os.ReadFile(path)
+		return string(b) == "3"
+	})
+	client.SetLastEventID("2")
+	if b, _ := os.ReadFile(path); string(b) != "2" {
+		t.Fatalf("persisted id = %q, want 2 after SetLastEventID", b)
+	}
+	client.trackEventID(&Message{ID: "3"})
+	// and when the client stops
+	client.Stop()
+	if b, _ := os.ReadFile(path); string(b) != "3" {
+		t.Fatalf("persisted id = %q, want 3 after Stop", b)
+	}
+
+	restarted := NewClient(server.URL, http.MethodGet, 0)
+	if err := restarted.PersistLastEventID(path); err != nil {
+		t.Fatalf("PersistLastEventID() error = %v", err)
+	}
+	if id := restarted.LastEventID(); id != "3" {
+		t.Fatalf("loaded id = %q, want 3", id)
+	}
+}
+
+func TestClient_ResumeFromHubHistory(t *testing.T) {
+	hub := NewHub(nil)
+	hub.SetHistory("default", 10)
+	sendHistory(t, hub, "default", 4)
+	server := httptest.NewServer(hub.Handler())
+	defer server.Close()
+
+	client := NewClient(server.URL, http.MethodGet, 0)
+	client.SetLastEventID("2")
+	received := make(chan string, 10)
+	client.SubscribeEvent("a", func(message *Message) {
+		received <- message.ID
+	})
+	client.SubscribeEvent("b", func(message *Message) {
+		received <- message.ID
+	})
+	go client.Start()
+	defer client.Stop()
+	got := map[string]bool{}
+	for len(got) < 2 {
+		select {
+		case id := <-received:
+			got[id] = true
+		case <-time.After(2 * time.Second):
+			t.Fatalf("replayed %v, want 3 and 4", got)
+		}
+	}
+	if !got["3"] || !got["4"] {
+		t.Fatalf("replayed %v, want 3 and 4", got)
+	}
+	if id := client.LastEventID(); id != "4" {
+		t.Fatalf("LastEventID() = %q, want 4", id)
+	}
+}
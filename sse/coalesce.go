@@ -0,0 +1,108 @@
+package sse
+
+import "sync"
+
+// coalescer pending queue of a Link for coalesced messages
+// only the newest message of each key is kept until the connection writes them
+type coalescer struct {
+	mu     sync.Mutex
+	keys   []string            //键首次进入队列的顺序
+	latest map[string]*Message //每个键最新的消息
+	ready  chan struct{}       //队列非空时可读
+}
+
+// coalesced message taken from the queue with its key
+type coalesced struct {
+	key     string
+	message *Message
+}
+
+func newCoalescer() *coalescer {
+	return &coalescer{
+		latest: make(map[string]*Message),
+		ready:  make(chan struct{}, 1),
+	}
+}
+
+// put queue message under key, replacing the pending message of the same key
+func (c *coalescer) put(key string, message *Message) {
+	c.mu.Lock()
+	if _, ok := c.latest[key]; !ok {
+		c.keys = append(c.keys, key)
+	}
+	c.latest[key] = message
+	c.mu.Unlock()
+	select {
+	case c.ready <- struct{}{}:
+	default:
+	}
+}
+
+// take empty the queue, messages are returned in the order their keys were first queued
+func (c *coalescer) take() []coalesced {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	messages := make([]coalesced, 0, len(c.keys))
+	for _, key := range c.keys {
+		messages = append(messages, coalesced{key: key, message: c.latest[key]})
+	}
+	c.keys = nil
+	c.latest = make(map[string]*Message)
+	return messages
+}
+
+// CoalesceByEvent coalesce key using the event name, e.g. for progress bars and live counters
+func CoalesceByEvent(message *Message) string {
+	return message.Event
+}
+
+// SetCoalesce enable coalescing for every message sent to zone,
+// key returns the coalesce key of a message (empty means not coalesced), nil disables it.
+// Packet.CoalesceKey takes precedence over the zone setting
+func (hub *Hub) SetCoalesce(zone string, key func(message *Message) string) {
+	hub.cblock.Lock()
+	defer hub.cblock.Unlock()
+	if key == nil {
+		delete(hub.coalesce, zone)
+		return
+	}
+	hub.coalesce[zone] = key
+}
+
+// coalesceKey the coalesce key of message sent to zone
+func (hub *Hub) coalesceKey(zone, packetKey string, message *Message) string {
+	if packetKey != "" {
+		return packetKey
+	}
+	hub.cblock.RLock()
+	key, ok := hub.coalesce[zone]
+	hub.cblock.RUnlock()
+	if !ok {
+		return ""
+	}
+	return key(message)
+}
+
+// pushResult outcome of Link.push
+type pushResult int
+
+const (
+	pushBlocked   pushResult = iota //messageChan 已满,未推送
+	pushSent                        //已写入 messageChan
+	pushCoalesced                   //进入合并队列,发送前可能被相同合并键的新消息替换
+)
+
+// push deliver message to the link without blocking,
+// messages with a coalesce key go to the pending queue instead of messageChan
+func (l Link) push(message *Message, key string) pushResult {
+	if key != "" && l.pending != nil {
+		l.pending.put(key, message)
+		return pushCoalesced
+	}
+	select {
+	case l.messageChan <- message:
+		return pushSent
+	default:
+		return pushBlocked
+	}
+}
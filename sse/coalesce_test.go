@@ -0,0 +1,89 @@
+package sse
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCoalescer(t *testing.T) {
+	c := newCoalescer()
+	c.put("progress", &Message{Data: "1"})
+	c.put("counter", &Message{Data: "a"})
+	c.put("progress", &Message{Data: "2"})
+
+	select {
+	case <-c.ready:
+	default:
+		t.Fatal("put() did not signal ready")
+	}
+	got := c.take()
+	if len(got) != 2 || got[0].key != "progress" || got[0].message.Data != "2" || got[1].message.Data != "a" {
+		t.Fatalf("take() = %+v", got)
+	}
+	if len(c.take()) != 0 {
+		t.Fatal("take() did not empty the queue")
+	}
+}
+
+func TestHub_coalesceKey(t *testing.T) {
+	hub := NewHub(nil)
+	message := &Message{Event: "progress"}
+	if key := hub.coalesceKey("zone", "", message); key != "" {
+		t.Fatalf("coalesceKey() = %q, want disabled", key)
+	}
+	hub.SetCoalesce("zone", CoalesceByEvent)
+	if key := hub.coalesceKey("zone", "", message); key != "progress" {
+		t.Fatalf("coalesceKey() = %q, want progress", key)
+	}
+	if key := hub.coalesceKey("zone", "packet", message); key != "packet" {
+		t.Fatalf("coalesceKey() = %q, want packet key first", key)
+	}
+	hub.SetCoalesce("zone", nil)
+	if key := hub.coalesceKey("zone", "", message); key != "" {
+		t.Fatalf("coalesceKey() = %q, want disabled", key)
+	}
+}
+
+func TestHub_SendMessageCoalesce(t *testing.T) {
+	hub := NewHub(nil)
+	link := Link{messageChan: make(chan *Message), allowPush: make(chan struct{}), pending: newCoalescer()}
+	addLinks(hub, "zone", map[string]Link{"client": link})
+
+	for _, data := range []string{"10%", "20%", "30%"} {
+		err := hub.SendMessage(Packet{Message: &Message{Event: "progress", Data: data}, Zone: "zone", ClientID: "client", CoalesceKey: "job-1"})
+		if err != nil {
+			t.Fatalf("SendMessage() error = %v", err)
+		}
+	}
+	hub.SetCoalesce("zone", CoalesceByEvent)
+	_ = hub.SendMessage(Packet{Message: &Message{Event: "counter", Data: "1"}, Zone: "zone", Broadcast: true})
+	_ = hub.SendMessage(Packet{Message: &Message{Event: "counter", Data: "2"}, Zone: "zone", Broadcast: true})
+
+	got := link.pending.take()
+	if len(got) != 2 || got[0].message.Data != "30%" || got[1].message.Data != "2" {
+		t.Fatalf("pending = %+v, want newest of each key", got)
+	}
+}
+
+func TestHub_RegisterPollCoalesce(t *testing.T) {
+	hub := NewHub(nil)
+	hub.PollTimeout = 20 * time.Millisecond
+	hub.SetCoalesce("zone", CoalesceByEvent)
+	first := poll(t, hub, "")
+
+	for _, data := range []string{"1", "2", "3"} {
+		_ = hub.SendMessage(Packet{Message: &Message{Event: "counter", Data: data}, Zone: "zone", ClientID: first.ClientID})
+		time.Sleep(time.Millisecond)
+	}
+	var resp PollResponse
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		resp = poll(t, hub, "id="+first.ClientID+"&cursor=1")
+		if len(resp.Messages) > 0 && resp.Messages[len(resp.Messages)-1].Data == "3" {
+			break
+		}
+	}
+	if len(resp.Messages) != 1 || resp.Messages[0].Data != "3" {
+		t.Fatalf("poll = %+v, want only the newest counter", resp)
+	}
+}
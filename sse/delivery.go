@@ -0,0 +1,116 @@
+package sse
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// DeliveryReport result of SendMessageContext, each list holds client IDs.
+// Delivered means handed to the connection, Coalesced only queued: a newer message
+// with the same coalesce key may replace it before it is written
+type DeliveryReport struct {
+	Targeted  int      `json:"targeted"`  //匹配到的连接数
+	Delivered []string `json:"delivered"` //已送达
+	Coalesced []string `json:"coalesced"` //进入合并队列,可能被相同合并键的新消息替换
+	Dropped   []string `json:"dropped"`   //通道阻塞且 ctx 不可等待,已丢弃
+	TimedOut  []string `json:"timed_out"` //等待至 ctx 结束仍未送达
+}
+
+// deliveryCollector collect fan-out results of SendMessageContext
+type deliveryCollector struct {
+	mu        sync.Mutex
+	delivered []string
+	coalesced []string
+	blocked   []target
+}
+
+// collect the push result of t
+func (c *deliveryCollector) collect(t target, result pushResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch result {
+	case pushSent:
+		c.delivered = append(c.delivered, t.id)
+	case pushCoalesced:
+		c.coalesced = append(c.coalesced, t.id)
+	default:
+		c.blocked = append(c.blocked, t)
+	}
+}
+
+// SendMessageContext sends messages like SendMessage and reports the delivery of every targeted link.
+// links that are blocked are waited for until ctx is done (timed out),
+// when ctx can never be done (e.g. context.Background) they are dropped immediately.
+// all broadcasts are delivered synchronously instead of through the broadcast channel
+func (hub *Hub) SendMessageContext(ctx context.Context, pkg Packet) (*DeliveryReport, error) {
+	report := &DeliveryReport{Delivered: []string{}, Coalesced: []string{}, Dropped: []string{}, TimedOut: []string{}}
+	if err := hub.checkPolicy(pkg); err != nil {
+		return report, err
+	}
+	pkg = hub.retain(pkg)
+	lr := len(pkg.Zone)
+	ld := len(pkg.ClientID)
+	if lr != 0 {
+		exists, count := hub.cons.zone(pkg.Zone)
+		if !exists {
+			return report, fmt.Errorf("zone not exist")
+		}
+		if count == 0 {
+			return report, ErrNoConnections
+		}
+	}
+	collector := &deliveryCollector{}
+	switch {
+	case pkg.Broadcast && ld == 0:
+		hub.fanoutCollect(pkg.Zone, pkg.Message, pkg.CoalesceKey, collector)
+	case ld != 0:
+		link, ok := hub.cons.get(pkg.Zone, pkg.ClientID)
+		if !ok {
+			return report, nil
+		}
+		t := target{zone: pkg.Zone, id: pkg.ClientID, link: link}
+		result := link.push(pkg.Message, hub.coalesceKey(pkg.Zone, pkg.CoalesceKey, pkg.Message))
+		if result != pushBlocked {
+			hub.broadcastReply(t.zone, t.id, pkg.Message)
+		}
+		collector.collect(t, result)
+	}
+	report.Delivered = append(report.Delivered, collector.delivered...)
+	report.Coalesced = append(report.Coalesced, collector.coalesced...)
+	hub.waitBlocked(ctx, pkg.Message, collector.blocked, report)
+	report.Targeted = len(report.Delivered) + len(report.Coalesced) + len(report.Dropped) + len(report.TimedOut)
+	return report, nil
+}
+
+// waitBlocked keep pushing to blocked links until ctx is done
+func (hub *Hub) waitBlocked(ctx context.Context, message *Message, blocked []target, report *DeliveryReport) {
+	if ctx.Done() == nil {
+		for _, t := range blocked {
+			report.Dropped = append(report.Dropped, t.id)
+		}
+		return
+	}
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	wg.Add(len(blocked))
+	for _, t := range blocked {
+		go func(t target) {
+			defer wg.Done()
+			select {
+			case t.link.messageChan <- message:
+				hub.broadcastReply(t.zone, t.id, message)
+				mu.Lock()
+				report.Delivered = append(report.Delivered, t.id)
+				mu.Unlock()
+			case <-ctx.Done():
+				mu.Lock()
+				report.TimedOut = append(report.TimedOut, t.id)
+				mu.Unlock()
+			}
+		}(t)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,90 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestHub_SendMessageContext(t *testing.T) {
+	newHub := func() (*Hub, Link) {
+		hub := NewHub(nil)
+		waiting := Link{messageChan: make(chan *Message), allowPush: make(chan struct{})}
+		addLinks(hub, "zone", map[string]Link{
+			"ready":   {messageChan: make(chan *Message, 1), allowPush: make(chan struct{})},
+			"blocked": {messageChan: make(chan *Message), allowPush: make(chan struct{})},
+			"waiting": waiting,
+		})
+		addLinks(hub, "other", map[string]Link{
+			"coalesced": {messageChan: make(chan *Message), allowPush: make(chan struct{}), pending: newCoalescer()},
+		})
+		return hub, waiting
+	}
+
+	t.Run("zone broadcast without deadline drops blocked links", func(t *testing.T) {
+		hub, _ := newHub()
+		report, err := hub.SendMessageContext(context.Background(), Packet{Message: &Message{Data: "data"}, Zone: "zone", Broadcast: true})
+		if err != nil {
+			t.Fatalf("SendMessageContext() error = %v", err)
+		}
+		sort.Strings(report.Dropped)
+		if report.Targeted != 3 || len(report.Delivered) != 1 || report.Delivered[0] != "ready" ||
+			len(report.Dropped) != 2 || report.Dropped[0] != "blocked" || len(report.TimedOut) != 0 {
+			t.Fatalf("report = %+v", report)
+		}
+	})
+
+	t.Run("all broadcast waits until deadline", func(t *testing.T) {
+		hub, waiting := newHub()
+		go func() {
+			<-waiting.messageChan
+		}()
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		report, err := hub.SendMessageContext(ctx, Packet{Message: &Message{Data: "data"}, Broadcast: true, CoalesceKey: "key"})
+		if err != nil {
+			t.Fatalf("SendMessageContext() error = %v", err)
+		}
+		sort.Strings(report.Delivered)
+		// the coalescing link only queued the message
+		if report.Targeted != 4 || len(report.Delivered) != 2 || len(report.Coalesced) != 1 || report.Coalesced[0] != "coalesced" ||
+			len(report.TimedOut) != 1 || report.TimedOut[0] != "blocked" {
+			t.Fatalf("report = %+v", report)
+		}
+	})
+
+	t.Run("direct send", func(t *testing.T) {
+		hub, _ := newHub()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		report, _ := hub.SendMessageContext(ctx, Packet{Message: &Message{Data: "data"}, Zone: "zone", ClientID: "blocked"})
+		if report.Targeted != 1 || len(report.TimedOut) != 1 {
+			t.Fatalf("report = %+v", report)
+		}
+		report, _ = hub.SendMessageContext(ctx, Packet{Message: &Message{Data: "data"}, Zone: "zone", ClientID: "ready"})
+		if report.Targeted != 1 || len(report.Delivered) != 1 {
+			t.Fatalf("report = %+v", report)
+		}
+		report, _ = hub.SendMessageContext(ctx, Packet{Message: &Message{Data: "data"}, Zone: "zone", ClientID: "missing"})
+		if report.Targeted != 0 {
+			t.Fatalf("report = %+v", report)
+		}
+		report, _ = hub.SendMessageContext(ctx, Packet{Message: &Message{Data: "data"}, Zone: "other", ClientID: "coalesced", CoalesceKey: "key"})
+		if report.Targeted != 1 || len(report.Delivered) != 0 || len(report.Coalesced) != 1 {
+			t.Fatalf("report = %+v, want the message coalesced", report)
+		}
+	})
+
+	t.Run("zone errors", func(t *testing.T) {
+		hub, _ := newHub()
+		if _, err := hub.SendMessageContext(context.Background(), Packet{Message: &Message{Data: "data"}, Zone: "missing", Broadcast: true}); err == nil {
+			t.Fatal("SendMessageContext() expected zone not exist error")
+		}
+		addLinks(hub, "empty", map[string]Link{})
+		if _, err := hub.SendMessageContext(context.Background(), Packet{Message: &Message{Data: "data"}, Zone: "empty", Broadcast: true}); !errors.Is(err, ErrNoConnections) {
+			t.Fatal("SendMessageContext() expected no connections error")
+		}
+	})
+}
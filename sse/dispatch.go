@@ -0,0 +1,67 @@
+package sse
+
+// defaultEventsBuffer capacity of the channel returned by Client.Events
+const defaultEventsBuffer = 16
+
+// Events every decoded message in the order the server sent it, besides the subscribed callbacks.
+// Reading stops while the channel is full, so a slow consumer slows down the stream instead of
+// losing messages. The channel is closed once the client stops. Call it before Start/Run
+func (c *Client) Events() <-chan *Message {
+	if c.events == nil {
+		c.events = make(chan *Message, defaultEventsBuffer)
+	}
+	return c.events
+}
+
+// SetSequential run the callbacks one at a time in the order the server sent the messages,
+// instead of a goroutine per message. Up to queueSize messages wait for a slow callback,
+// after that reading stops until the queue drains. Call it before Start/Run
+func (c *Client) SetSequential(queueSize int) {
+	if queueSize < 0 {
+		queueSize = 0
+	}
+	c.queue = make(chan *Message, queueSize)
+}
+
+// dispatch hand message to the callbacks and Events, false when the client stopped while waiting
+func (c *Client) dispatch(message *Message) bool {
+	if c.queue != nil {
+		select {
+		case c.queue <- message:
+		case <-c.exitSignal:
+			return false
+		}
+	} else {
+		for _, callback := range c.messageHandlers(message) {
+			go callback(message)
+		}
+	}
+	if c.events != nil {
+		select {
+		case c.events <- message:
+		case <-c.exitSignal:
+			return false
+		}
+	}
+	return true
+}
+
+// runQueue call the callbacks of queued messages until the queue is closed
+func (c *Client) runQueue() {
+	for message := range c.queue {
+		for _, callback := range c.messageHandlers(message) {
+			callback(message)
+		}
+	}
+}
+
+// closeDispatch close the queue and Events after the last message was dispatched,
+// the messages still queued are handled before runQueue returns
+func (c *Client) closeDispatch() {
+	if c.queue != nil {
+		close(c.queue)
+	}
+	if c.events != nil {
+		close(c.events)
+	}
+}
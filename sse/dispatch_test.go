@@ -0,0 +1,121 @@
+package sse
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// streamServer serve n messages with IDs 1..n then keep the stream open
+func streamServer(n int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := 1; i <= n; i++ {
+			fmt.Fprintf(w, "id: %d\nevent: tick\ndata: %d\n\n", i, i)
+		}
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+}
+
+func TestClient_SetSequential(t *testing.T) {
+	server := streamServer(100)
+	defer server.Close()
+
+	var (
+		mu  sync.Mutex
+		ids []int
+	)
+	done := make(chan struct{})
+	client := NewClient(server.URL, http.MethodGet, time.Second)
+	client.SetSequential(4)
+	client.SubscribeEvent("tick", func(message *Message) {
+		id, _ := strconv.Atoi(message.ID)
+		mu.Lock()
+		ids = append(ids, id)
+		n := len(ids)
+		mu.Unlock()
+		if n == 100 {
+			close(done)
+		}
+	})
+	go client.Start()
+	defer client.Stop()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("callbacks were not called for every message")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for i, id := range ids {
+		if id != i+1 {
+			t.Fatalf("callback %d got message %d, want in order", i, id)
+		}
+	}
+}
+
+func TestClient_Events(t *testing.T) {
+	server := streamServer(50)
+	defer server.Close()
+
+	client := NewClient(server.URL, http.MethodGet, time.Second)
+	events := client.Events()
+	if client.Events() != events {
+		t.Fatal("Events() returned a different channel")
+	}
+	go client.Start()
+
+	for i := 1; i <= 50; i++ {
+		select {
+		case message := <-events:
+			if message.ID != strconv.Itoa(i) {
+				t.Fatalf("message %d has ID %s, want in order", i, message.ID)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("message %d was not received", i)
+		}
+	}
+
+	client.Stop()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Fatal("Events() got a message after the stream was drained")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Events() was not closed after Stop")
+	}
+}
+
+func TestClient_EventsBackpressure(t *testing.T) {
+	server := streamServer(100)
+	defer server.Close()
+
+	client := NewClient(server.URL, http.MethodGet, time.Second)
+	events := client.Events()
+	go client.Start()
+
+	// nobody reads, the client must stop at the buffer and still shut down cleanly
+	time.Sleep(100 * time.Millisecond)
+	if got := len(events); got != cap(events) {
+		t.Fatalf("buffered = %d, want %d", got, cap(events))
+	}
+	client.Stop()
+	deadline := time.After(2 * time.Second)
+	for {
+		select {
+		case _, ok := <-events:
+			if !ok {
+				return
+			}
+		case <-deadline:
+			t.Fatal("Events() was not closed after Stop")
+		}
+	}
+}
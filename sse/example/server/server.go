@@ -144,6 +144,12 @@ func main() {
 	http.HandleFunc("/sse", func(w http.ResponseWriter, r *http.Request) {
 		h.RegisterBlock(w, r, "default", nil)
 	})
+	http.HandleFunc("/poll", func(w http.ResponseWriter, r *http.Request) {
+		h.RegisterPoll(w, r, "default", nil)
+	})
+	http.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		h.RegisterWebSocket(w, r, "default", nil)
+	})
 	http.HandleFunc("/broadcast", send)
 	http.HandleFunc("/send", send)
 	http.HandleFunc("/loop", loop)
@@ -0,0 +1,163 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrFailback the stream was closed to move back to the preferred endpoint
+var ErrFailback = errors.New("sse: failing back to the preferred endpoint")
+
+// Failover endpoints of a Client served by several servers, e.g. one per region
+type Failover struct {
+	Endpoints     []string                 //按优先级排列的地址,第一个为首选
+	Resolve       func() ([]string, error) //返回地址列表,启动及每次切换时调用,优先于 Endpoints
+	Threshold     int                      //同一地址连续失败多少次后切换至下一个,默认 3
+	ProbeInterval time.Duration            //连接非首选地址时探测首选地址的间隔,恢复后切回,为 0 不切回
+	ProbeTimeout  time.Duration            //单次探测的超时,默认 5s
+}
+
+// failoverState the endpoint in use
+type failoverState struct {
+	Failover
+	mu       sync.Mutex
+	urls     []string
+	index    int //当前地址的下标
+	failures int //当前地址连续失败次数
+}
+
+// SetFailover connect to the endpoints of f instead of the url of NewClient, in order of preference.
+// After Threshold consecutive failures the client moves to the next endpoint, the Last-Event-ID
+// carries over so the stream resumes where it stopped. Permanent statuses (204, 4xx) still stop
+// the client and Backoff.MaxAttempts counts the failures of all endpoints. Call it before Start/Run
+func (c *Client) SetFailover(f Failover) {
+	if f.Threshold <= 0 {
+		f.Threshold = 3
+	}
+	if f.ProbeTimeout <= 0 {
+		f.ProbeTimeout = 5 * time.Second
+	}
+	s := &failoverState{Failover: f, urls: f.Endpoints}
+	s.resolve()
+	c.failover = s
+}
+
+// URL the endpoint the client connects to
+func (c *Client) URL() string {
+	if c.failover == nil {
+		return c.url
+	}
+	c.failover.mu.Lock()
+	defer c.failover.mu.Unlock()
+	return c.failover.current(c.url)
+}
+
+// current the url in use, fallback when there is no endpoint, s.mu must be held
+func (s *failoverState) current(fallback string) string {
+	if len(s.urls) == 0 {
+		return fallback
+	}
+	return s.urls[s.index%len(s.urls)]
+}
+
+// resolve refresh the endpoints from Resolve, the previous list is kept on errors
+func (s *failoverState) resolve() {
+	if s.Resolve == nil {
+		return
+	}
+	urls, err := s.Resolve()
+	if err != nil || len(urls) == 0 {
+		log.Printf("resolve SSE endpoints fail:%+v\n", err)
+		return
+	}
+	s.urls = urls
+}
+
+// failed record a failed attempt, move to the next endpoint after Threshold failures
+func (s *failoverState) failed() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures++
+	if s.failures < s.Threshold {
+		return
+	}
+	from := s.current("")
+	s.failures = 0
+	s.index++
+	s.resolve()
+	if len(s.urls) > 0 {
+		s.index %= len(s.urls)
+	}
+	log.Printf("SSE endpoint %s failed, switching to %s\n", from, s.current(""))
+}
+
+// endpointFailed count a failed attempt against the endpoint in use
+func (c *Client) endpointFailed() {
+	if c.failover != nil {
+		c.failover.failed()
+	}
+}
+
+// opened the current endpoint works, true when it is not the preferred one
+func (s *failoverState) opened() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = 0
+	return s.index != 0 && len(s.urls) > 1
+}
+
+// preferred move back to the first endpoint
+func (s *failoverState) preferred() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.index = 0
+	s.failures = 0
+	return s.current("")
+}
+
+// probe check the preferred endpoint every ProbeInterval until done is closed,
+// failback is signalled once it answers 200
+func (c *Client) probe(done <-chan struct{}, failback chan<- struct{}) {
+	ticker := time.NewTicker(c.failover.ProbeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+		c.failover.mu.Lock()
+		url := c.failover.urls[0]
+		c.failover.mu.Unlock()
+		if c.probeEndpoint(url) {
+			select {
+			case failback <- struct{}{}:
+			default:
+			}
+			return
+		}
+	}
+}
+
+// probeEndpoint open a stream to url and close it right away, true when it answers 200 with an event stream
+func (c *Client) probeEndpoint(url string) bool {
+	req, err := c.streamRequest(url)
+	if err != nil {
+		return false
+	}
+	if err = c.applyHeaders(req); err != nil {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.failover.ProbeTimeout)
+	defer cancel()
+	resp, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return false
+	}
+	_ = resp.Body.Close()
+	return resp.StatusCode == http.StatusOK && isEventStream(resp.Header.Get("Content-Type"))
+}
@@ -0,0 +1,166 @@
+package sse
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitFor poll cond until it holds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestClient_Failover(t *testing.T) {
+	var primaryRequests int32
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&primaryRequests, 1) > 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "id: 1\ndata: a\n\n")
+	}))
+	defer primary.Close()
+	lastIDs := make(chan string, 1)
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case lastIDs <- r.Header.Get("Last-Event-ID"):
+		default:
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "id: 2\ndata: b\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer secondary.Close()
+
+	client := NewClient("http://unused.example", http.MethodGet, time.Millisecond)
+	client.SetFailover(Failover{Endpoints: []string{primary.URL, secondary.URL}, Threshold: 2})
+	if client.URL() != primary.URL {
+		t.Fatalf("URL() = %s, want the preferred endpoint", client.URL())
+	}
+	go client.Start()
+	defer client.Stop()
+
+	select {
+	case id := <-lastIDs:
+		if id != "1" {
+			t.Fatalf("Last-Event-ID on the secondary = %q, want 1", id)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("client did not fail over")
+	}
+	if client.URL() != secondary.URL {
+		t.Fatalf("URL() = %s, want the secondary endpoint", client.URL())
+	}
+	// the end of the stream counts as the first failure, the 502 reaches Threshold
+	if got := atomic.LoadInt32(&primaryRequests); got != 2 {
+		t.Fatalf("primary requests = %d, want 2", got)
+	}
+}
+
+func TestClient_FailoverProbe(t *testing.T) {
+	var primaryUp int32
+	lastIDs := make(chan string, 1)
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&primaryUp) == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.(http.Flusher).Flush()
+		select {
+		case lastIDs <- r.Header.Get("Last-Event-ID"):
+		default:
+		}
+		<-r.Context().Done()
+	}))
+	defer primary.Close()
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "id: 7\ndata: b\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer secondary.Close()
+
+	client := NewClient("http://unused.example", http.MethodGet, time.Millisecond)
+	client.SetFailover(Failover{Endpoints: []string{primary.URL, secondary.URL}, Threshold: 1, ProbeInterval: 20 * time.Millisecond})
+	reasons := make(chan string, 4)
+	client.OnDisconnect(func(reason string) {
+		reasons <- reason
+	})
+	go client.Start()
+	defer client.Stop()
+
+	waitFor(t, "the secondary stream", func() bool { return client.URL() == secondary.URL && client.LastEventID() == "7" })
+	atomic.StoreInt32(&primaryUp, 1)
+
+	// the probe itself also reaches the primary, the stream request follows it
+	for i := 0; ; i++ {
+		select {
+		case <-lastIDs:
+		case <-time.After(2 * time.Second):
+			t.Fatal("client did not fail back")
+		}
+		if client.URL() == primary.URL {
+			break
+		}
+		if i > 2 {
+			t.Fatal("client did not switch to the primary")
+		}
+	}
+	select {
+	case reason := <-reasons:
+		if reason != ErrFailback.Error() {
+			t.Fatalf("disconnect reason = %q, want %q", reason, ErrFailback.Error())
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("disconnect handler was not called")
+	}
+	waitFor(t, "the primary stream", func() bool { return client.ReadyState() == Open && client.URL() == primary.URL })
+	if client.LastEventID() != "7" {
+		t.Fatalf("LastEventID() = %q, want 7", client.LastEventID())
+	}
+}
+
+func TestClient_FailoverResolve(t *testing.T) {
+	var calls int32
+	client := NewClient("http://unused.example", http.MethodGet, time.Millisecond)
+	client.SetFailover(Failover{
+		Threshold: 1,
+		Resolve: func() ([]string, error) {
+			switch atomic.AddInt32(&calls, 1) {
+			case 1:
+				return []string{"http://a.example", "http://b.example"}, nil
+			case 2:
+				return nil, errors.New("dns failure")
+			default:
+				return []string{"http://c.example"}, nil
+			}
+		},
+	})
+	if client.URL() != "http://a.example" {
+		t.Fatalf("URL() = %s", client.URL())
+	}
+	client.endpointFailed() // resolve fails, the previous list is kept
+	if client.URL() != "http://b.example" {
+		t.Fatalf("URL() = %s, want b", client.URL())
+	}
+	client.endpointFailed()
+	if client.URL() != "http://c.example" {
+		t.Fatalf("URL() = %s, want the resolved c", client.URL())
+	}
+}
@@ -42,3 +42,15 @@ func (d *Decoder) Decode() (*Message, error) {
 		}
 	}
 }
+
+// Text the Data of a decoded message without the newline the Decoder ends every data line with,
+// the lines of multi-line data stay separated by newlines
+func (m *Message) Text() string {
+	return strings.TrimSuffix(m.Data, "\n")
+}
+
+// dataLines the lines of data, each one is written as a data field. CRLF and CR end a line too
+func dataLines(data string) []string {
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	return strings.Split(strings.ReplaceAll(data, "\r", "\n"), "\n")
+}
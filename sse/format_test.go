@@ -210,3 +210,17 @@ func TestDecoder_DecodeEmptyLines(t *testing.T) {
 		// This is expected behavior - empty lines create empty messages
 	}
 }
+
+func TestMessage_Text(t *testing.T) {
+	message, err := NewDecoder(strings.NewReader("data: line1\ndata: line2\n\n")).Decode()
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if got := message.Text(); got != "line1\nline2" {
+		t.Fatalf("Text() = %q", got)
+	}
+	formatted, _ := (&Message{Data: message.Text()}).Format()
+	if again, _ := NewDecoder(strings.NewReader(formatted.String())).Decode(); again.Data != message.Data {
+		t.Fatalf("Format() then Decode() = %q, want %q", again.Data, message.Data)
+	}
+}
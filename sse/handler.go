@@ -0,0 +1,180 @@
+package sse
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HandlerOption configures the http.Handler returned by Hub.Handler
+type HandlerOption func(*handlerConfig)
+
+// handlerConfig options of Hub.Handler
+type handlerConfig struct {
+	zone        func(r *http.Request) string //Zone 提取,默认 default
+	id          func(r *http.Request) string //连接ID生成,返回空字符串时使用默认生成
+	origins     []string                     //CORS 允许的 Origin,nil 表示不处理 CORS
+	credentials bool                         //允许跨域请求携带 Cookie 等凭据
+	headers     http.Header                  //额外响应头
+	retry       time.Duration                //客户端重连间隔提示
+}
+
+// Handler returns an http.Handler that registers SSE connections (see RegisterBlock),
+// so it can be mounted on http.ServeMux or wrapped by middlewares directly
+//
+//	mux.Handle("/events/", h.Handler(sse.WithZoneFromPath("/events/"), sse.WithCORS("*")))
+func (hub *Hub) Handler(opts ...HandlerOption) http.Handler {
+	cfg := &handlerConfig{headers: http.Header{}}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cfg.origins != nil && !cfg.cors(w, r) {
+			return
+		}
+		for key, values := range cfg.headers {
+			for _, v := range values {
+				w.Header().Add(key, v)
+			}
+		}
+		zone := ""
+		if cfg.zone != nil {
+			zone = cfg.zone(r)
+		}
+		var uuid func() string
+		if cfg.id != nil {
+			if id := cfg.id(r); id != "" {
+				uuid = func() string {
+					return id
+				}
+			}
+		}
+		retry := ""
+		if cfg.retry > 0 {
+			retry = strconv.FormatInt(cfg.retry.Milliseconds(), 10)
+		}
+		hub.registerBlock(w, r, zone, uuid, retry)
+	})
+}
+
+// cors write CORS headers, reports whether the request should be served (false for preflight)
+func (cfg *handlerConfig) cors(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	allowed := ""
+	for _, o := range cfg.origins {
+		if o == "*" {
+			allowed = "*"
+			break
+		}
+		if origin != "" && strings.EqualFold(o, origin) {
+			allowed = origin
+			break
+		}
+	}
+	if allowed == "" && len(cfg.origins) == 0 {
+		allowed = "*"
+	}
+	if allowed != "" {
+		w.Header().Set("Access-Control-Allow-Origin", allowed)
+		if allowed != "*" {
+			if cfg.credentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+			w.Header().Add("Vary", "Origin")
+		}
+	}
+	if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+		if allowed != "" {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Last-Event-ID, Cache-Control, Content-Type, Authorization")
+			w.Header().Set("Access-Control-Max-Age", "86400")
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return false
+	}
+	return true
+}
+
+// WithZone every connection joins zone
+func WithZone(zone string) HandlerOption {
+	return func(cfg *handlerConfig) {
+		cfg.zone = func(*http.Request) string {
+			return zone
+		}
+	}
+}
+
+// WithZoneFromPath zone is the first path segment after prefix, e.g. prefix "/events/" and path "/events/orders" joins "orders"
+func WithZoneFromPath(prefix string) HandlerOption {
+	return func(cfg *handlerConfig) {
+		cfg.zone = func(r *http.Request) string {
+			zone := strings.TrimPrefix(r.URL.Path, prefix)
+			if i := strings.IndexByte(zone, '/'); i >= 0 {
+				zone = zone[:i]
+			}
+			return zone
+		}
+	}
+}
+
+// WithZoneFromQuery zone is the query parameter key
+func WithZoneFromQuery(key string) HandlerOption {
+	return func(cfg *handlerConfig) {
+		cfg.zone = func(r *http.Request) string {
+			return r.URL.Query().Get(key)
+		}
+	}
+}
+
+// WithZoneFromHeader zone is the request header name
+func WithZoneFromHeader(name string) HandlerOption {
+	return func(cfg *handlerConfig) {
+		cfg.zone = func(r *http.Request) string {
+			return r.Header.Get(name)
+		}
+	}
+}
+
+// WithZoneFunc zone is returned by fn, empty means default
+func WithZoneFunc(fn func(r *http.Request) string) HandlerOption {
+	return func(cfg *handlerConfig) {
+		cfg.zone = fn
+	}
+}
+
+// WithIDFunc connection ID is returned by fn (e.g. the authenticated user), empty uses the Hub generator
+func WithIDFunc(fn func(r *http.Request) string) HandlerOption {
+	return func(cfg *handlerConfig) {
+		cfg.id = fn
+	}
+}
+
+// WithCORS allow cross-origin EventSource from origins, no origin or "*" allows any
+func WithCORS(origins ...string) HandlerOption {
+	return func(cfg *handlerConfig) {
+		cfg.origins = append([]string{}, origins...)
+	}
+}
+
+// WithCORSCredentials allow credentialed requests (EventSource withCredentials) from the origins listed by WithCORS,
+// never sent for "*"
+func WithCORSCredentials() HandlerOption {
+	return func(cfg *handlerConfig) {
+		cfg.credentials = true
+	}
+}
+
+// WithHeader add an extra response header
+func WithHeader(key, value string) HandlerOption {
+	return func(cfg *handlerConfig) {
+		cfg.headers.Add(key, value)
+	}
+}
+
+// WithRetry reconnection time hint sent to the client with the first message
+func WithRetry(retry time.Duration) HandlerOption {
+	return func(cfg *handlerConfig) {
+		cfg.retry = retry
+	}
+}
@@ -0,0 +1,191 @@
+package sse
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveHandler run handler until the connected message is written, returns the recorder
+func serveHandler(t *testing.T, hub *Hub, handler http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	t.Helper()
+	connected := make(chan string, 1)
+	hub.ConnectedFunc = func(clientID string) {
+		connected <- clientID
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	recorder := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(recorder, req.WithContext(ctx))
+		close(done)
+	}()
+	select {
+	case <-connected:
+	case <-time.After(time.Second):
+		t.Fatal("handler did not register connection")
+	}
+	// the connected message is already taken by the write loop
+	cancel()
+	<-done
+	return recorder
+}
+
+func TestHub_Handler(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []HandlerOption
+		req      func() *http.Request
+		wantZone string
+		wantID   string
+	}{
+		{
+			name:     "default zone",
+			req:      func() *http.Request { return httptest.NewRequest(http.MethodGet, "/sse", nil) },
+			wantZone: "default",
+		},
+		{
+			name:     "static zone",
+			opts:     []HandlerOption{WithZone("static")},
+			req:      func() *http.Request { return httptest.NewRequest(http.MethodGet, "/sse", nil) },
+			wantZone: "static",
+		},
+		{
+			name:     "zone from path",
+			opts:     []HandlerOption{WithZoneFromPath("/events/")},
+			req:      func() *http.Request { return httptest.NewRequest(http.MethodGet, "/events/orders/x", nil) },
+			wantZone: "orders",
+		},
+		{
+			name:     "zone from query",
+			opts:     []HandlerOption{WithZoneFromQuery("room")},
+			req:      func() *http.Request { return httptest.NewRequest(http.MethodGet, "/sse?room=lobby", nil) },
+			wantZone: "lobby",
+		},
+		{
+			name: "zone from header and id func",
+			opts: []HandlerOption{WithZoneFromHeader("X-Zone"), WithIDFunc(func(r *http.Request) string {
+				return r.Header.Get("X-User")
+			})},
+			req: func() *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/sse", nil)
+				req.Header.Set("X-Zone", "tenant")
+				req.Header.Set("X-User", "user-1")
+				return req
+			},
+			wantZone: "tenant",
+			wantID:   "user-1",
+		},
+		{
+			name: "zone func",
+			opts: []HandlerOption{WithZoneFunc(func(r *http.Request) string {
+				return strings.ToUpper(r.URL.Query().Get("z"))
+			})},
+			req:      func() *http.Request { return httptest.NewRequest(http.MethodGet, "/sse?z=abc", nil) },
+			wantZone: "ABC",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := NewHub(nil)
+			var gotZone, gotID string
+			handler := hub.Handler(tt.opts...)
+			connected := make(chan struct{}, 1)
+			hub.ConnectedFunc = func(clientID string) {
+				for _, zone := range hub.cons.zoneNames() {
+					if _, ok := hub.cons.get(zone, clientID); ok {
+						gotZone, gotID = zone, clientID
+					}
+				}
+				connected <- struct{}{}
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				handler.ServeHTTP(httptest.NewRecorder(), tt.req().WithContext(ctx))
+				close(done)
+			}()
+			select {
+			case <-connected:
+			case <-time.After(time.Second):
+				t.Fatal("handler did not register connection")
+			}
+			cancel()
+			<-done
+			if gotZone != tt.wantZone {
+				t.Fatalf("zone = %q, want %q", gotZone, tt.wantZone)
+			}
+			if tt.wantID != "" && gotID != tt.wantID {
+				t.Fatalf("id = %q, want %q", gotID, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestHub_HandlerHeadersAndRetry(t *testing.T) {
+	hub := NewHub(nil)
+	handler := hub.Handler(WithHeader("X-Accel-Buffering", "no"), WithRetry(5*time.Second), WithCORS("https://app.example.com"))
+	req := httptest.NewRequest(http.MethodGet, "/sse", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+
+	recorder := serveHandler(t, hub, handler, req)
+	if got := recorder.Header().Get("X-Accel-Buffering"); got != "no" {
+		t.Fatalf("X-Accel-Buffering = %q", got)
+	}
+	if got := recorder.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Fatalf("Access-Control-Allow-Origin = %q", got)
+	}
+	if got := recorder.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Fatalf("Access-Control-Allow-Credentials = %q, want none without WithCORSCredentials", got)
+	}
+	if !strings.Contains(recorder.Body.String(), "retry: 5000\n") {
+		t.Fatalf("body = %q, want retry hint", recorder.Body.String())
+	}
+}
+
+func TestHub_HandlerCORS(t *testing.T) {
+	hub := NewHub(nil)
+	tests := []struct {
+		name            string
+		origins         []string
+		credentials     bool
+		origin          string
+		wantOrigin      string
+		wantCredentials string
+	}{
+		{name: "any origin", origins: nil, origin: "https://a.example.com", wantOrigin: "*"},
+		{name: "wildcard", origins: []string{"*"}, origin: "https://a.example.com", wantOrigin: "*"},
+		{name: "wildcard with credentials", origins: []string{"*"}, credentials: true, origin: "https://a.example.com", wantOrigin: "*"},
+		{name: "allowed origin", origins: []string{"https://a.example.com"}, origin: "https://a.example.com", wantOrigin: "https://a.example.com"},
+		{name: "allowed origin with credentials", origins: []string{"https://a.example.com"}, credentials: true, origin: "https://a.example.com", wantOrigin: "https://a.example.com", wantCredentials: "true"},
+		{name: "denied origin", origins: []string{"https://a.example.com"}, credentials: true, origin: "https://b.example.com", wantOrigin: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodOptions, "/sse", nil)
+			req.Header.Set("Origin", tt.origin)
+			req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+			recorder := httptest.NewRecorder()
+			opts := []HandlerOption{WithCORS(tt.origins...)}
+			if tt.credentials {
+				opts = append(opts, WithCORSCredentials())
+			}
+			hub.Handler(opts...).ServeHTTP(recorder, req)
+			if recorder.Code != http.StatusNoContent {
+				t.Fatalf("status = %d, want %d", recorder.Code, http.StatusNoContent)
+			}
+			if got := recorder.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Fatalf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+			if got := recorder.Header().Get("Access-Control-Allow-Credentials"); got != tt.wantCredentials {
+				t.Fatalf("Access-Control-Allow-Credentials = %q, want %q", got, tt.wantCredentials)
+			}
+			if tt.wantOrigin != "" && recorder.Header().Get("Access-Control-Allow-Headers") == "" {
+				t.Fatal("preflight did not allow headers")
+			}
+		})
+	}
+}
@@ -0,0 +1,280 @@
+package sse
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const defaultHistoryLimit = 50
+
+var (
+	// ErrHistoryDisabled the zone does not retain messages, see Hub.SetHistory
+	ErrHistoryDisabled = errors.New("history is not enabled for zone")
+	// ErrCursorNotFound the cursor message is no longer (or never was) retained
+	ErrCursorNotFound = errors.New("cursor not found in history")
+)
+
+// historyBuffer retained messages of a zone, oldest first
+type historyBuffer struct {
+	size     int
+	messages []*Message
+}
+
+func (b *historyBuffer) add(message *Message) {
+	if len(b.messages) >= b.size {
+		b.messages = b.messages[len(b.messages)-b.size+1:]
+	}
+	b.messages = append(b.messages, message)
+}
+
+// index position of the message with id, -1 if it is not retained
+func (b *historyBuffer) index(id string) int {
+	for i := len(b.messages) - 1; i >= 0; i-- {
+		if b.messages[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// HistoryQuery selects retained messages, see Hub.History
+type HistoryQuery struct {
+	Before string   //返回该ID之前的消息 (向前翻页)
+	After  string   //返回该ID之后的消息 (续传),优先于 Before
+	Events []string //事件名称过滤,为空不过滤
+	Limit  int      //最多返回条数,默认 50
+}
+
+// HistoryPage a page of retained messages, oldest first
+type HistoryPage struct {
+	Zone        string     `json:"zone"`
+	Messages    []*Message `json:"messages"`
+	Older       string     `json:"older,omitempty"`         //存在更早的消息时,作为 before 获取上一页
+	Newer       string     `json:"newer,omitempty"`         //存在更新的消息时,作为 after 获取下一页
+	LastEventID string     `json:"last_event_id,omitempty"` //本页覆盖到的最新消息ID,打开连接时作为 Last-Event-ID 续传
+}
+
+// SetHistory retain the last size messages broadcast to zone (all-zone broadcasts included), size <= 0 disables it.
+// messages without ID are given a ULID that is also used by the live stream,
+// so a connection opened with the Last-Event-ID header receives the retained messages after it first.
+// in zones with history the connected message carries the newest retained ID instead of the client ID
+func (hub *Hub) SetHistory(zone string, size int) {
+	hub.hblock.Lock()
+	defer hub.hblock.Unlock()
+	if size <= 0 {
+		delete(hub.history, zone)
+		return
+	}
+	hub.cons.ensure(zone)
+	if b, ok := hub.history[zone]; ok {
+		b.size = size
+		if len(b.messages) > size {
+			b.messages = append([]*Message{}, b.messages[len(b.messages)-size:]...)
+		}
+		return
+	}
+	hub.history[zone] = &historyBuffer{size: size}
+}
+
+// retain record broadcast messages in the history of their zones, returns pkg with the message ID assigned
+func (hub *Hub) retain(pkg Packet) Packet {
+	if !pkg.Broadcast || pkg.ClientID != "" || pkg.Message == nil {
+		return pkg
+	}
+	hub.hblock.Lock()
+	defer hub.hblock.Unlock()
+	var buffers []*historyBuffer
+	if pkg.Zone != "" {
+		if b, ok := hub.history[pkg.Zone]; ok {
+			buffers = append(buffers, b)
+		}
+	} else {
+		for _, b := range hub.history {
+			buffers = append(buffers, b)
+		}
+	}
+	if len(buffers) == 0 {
+		return pkg
+	}
+	if pkg.Message.ID == "" {
+		// copy so a Message reused by the caller does not keep the ID
+		message := *pkg.Message
+		message.ID = ULID()
+		pkg.Message = &message
+	}
+	for _, b := range buffers {
+		b.add(pkg.Message)
+	}
+	return pkg
+}
+
+// historyResume retained messages of zone after id (nil when id is not retained) and the newest retained ID,
+// ok is false when zone has no history
+func (hub *Hub) historyResume(zone, id string) (missed []*Message, last string, ok bool) {
+	hub.hblock.Lock()
+	defer hub.hblock.Unlock()
+	b, ok := hub.history[zone]
+	if !ok {
+		return nil, "", false
+	}
+	if n := len(b.messages); n > 0 {
+		last = b.messages[n-1].ID
+	}
+	if i := b.index(id); id != "" && i >= 0 {
+		missed = append([]*Message{}, b.messages[i+1:]...)
+	}
+	return missed, last, true
+}
+
+// History returns a page of the retained messages of zone,
+// the latest q.Limit messages by default, older ones with q.Before and newer ones with q.After
+func (hub *Hub) History(zone string, q HistoryQuery) (*HistoryPage, error) {
+	hub.hblock.Lock()
+	b, ok := hub.history[zone]
+	var messages []*Message
+	if ok {
+		messages = append(messages, b.messages...)
+	}
+	hub.hblock.Unlock()
+	if !ok {
+		return nil, ErrHistoryDisabled
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultHistoryLimit
+	}
+	match := func(message *Message) bool {
+		return len(q.Events) == 0 || containsString(q.Events, message.Event)
+	}
+	index := (&historyBuffer{messages: messages}).index
+	page := &HistoryPage{Zone: zone, Messages: []*Message{}}
+	first, last := -1, -1 //本页首尾消息的位置
+	end := len(messages)  //本页覆盖的范围 [0, end)
+	if q.After != "" {
+		i := index(q.After)
+		if i < 0 {
+			return nil, ErrCursorNotFound
+		}
+		for j := i + 1; j < len(messages); j++ {
+			if !match(messages[j]) {
+				continue
+			}
+			if len(page.Messages) == limit {
+				end = j
+				break
+			}
+			if first < 0 {
+				first = j
+			}
+			last = j
+			page.Messages = append(page.Messages, messages[j])
+		}
+	} else {
+		if q.Before != "" {
+			end = index(q.Before)
+			if end < 0 {
+				return nil, ErrCursorNotFound
+			}
+		}
+		for j := end - 1; j >= 0 && len(page.Messages) < limit; j-- {
+			if !match(messages[j]) {
+				continue
+			}
+			if last < 0 {
+				last = j
+			}
+			first = j
+			page.Messages = append(page.Messages, messages[j])
+		}
+		for i, k := 0, len(page.Messages)-1; i < k; i, k = i+1, k-1 {
+			page.Messages[i], page.Messages[k] = page.Messages[k], page.Messages[i]
+		}
+	}
+	hasMatch := func(list []*Message) bool {
+		for _, message := range list {
+			if match(message) {
+				return true
+			}
+		}
+		return false
+	}
+	if first >= 0 && hasMatch(messages[:first]) {
+		page.Older = messages[first].ID
+	}
+	if last >= 0 && hasMatch(messages[last+1:]) {
+		page.Newer = messages[last].ID
+	}
+	if end > 0 {
+		page.LastEventID = messages[end-1].ID
+	}
+	return page, nil
+}
+
+// HistoryHandler returns an http.Handler serving History of the zone as JSON, zone, CORS and header
+// options are the same as Handler. query parameters: limit, before, after (or the Last-Event-ID header)
+// and event (repeated or comma separated)
+//
+//	mux.Handle("/history/", h.HistoryHandler(sse.WithZoneFromPath("/history/")))
+func (hub *Hub) HistoryHandler(opts ...HandlerOption) http.Handler {
+	cfg := &handlerConfig{headers: http.Header{}}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cfg.origins != nil && !cfg.cors(w, r) {
+			return
+		}
+		for key, values := range cfg.headers {
+			for _, v := range values {
+				w.Header().Add(key, v)
+			}
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		zone := ""
+		if cfg.zone != nil {
+			zone = cfg.zone(r)
+		}
+		if zone == "" {
+			zone = "default"
+		}
+		query := r.URL.Query()
+		q := HistoryQuery{Before: query.Get("before"), After: query.Get("after")}
+		if q.After == "" && q.Before == "" {
+			q.After = r.Header.Get("Last-Event-ID")
+		}
+		if v := query.Get("limit"); v != "" {
+			limit, err := strconv.Atoi(v)
+			if err != nil || limit <= 0 {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+			q.Limit = limit
+		}
+		for _, v := range query["event"] {
+			for _, event := range strings.Split(v, ",") {
+				if event = strings.TrimSpace(event); event != "" {
+					q.Events = append(q.Events, event)
+				}
+			}
+		}
+		page, err := hub.History(zone, q)
+		switch {
+		case errors.Is(err, ErrHistoryDisabled):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case errors.Is(err, ErrCursorNotFound):
+			// the client has to reload the latest page
+			http.Error(w, err.Error(), http.StatusGone)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+		_ = json.NewEncoder(w).Encode(page)
+	})
+}
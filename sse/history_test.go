@@ -0,0 +1,192 @@
+package sse
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sendHistory broadcast n messages to zone with IDs 1..n, events alternate between "a" and "b"
+func sendHistory(t *testing.T, hub *Hub, zone string, n int) {
+	t.Helper()
+	for i := 1; i <= n; i++ {
+		event := "a"
+		if i%2 == 0 {
+			event = "b"
+		}
+		err := hub.SendMessage(Packet{Zone: zone, Broadcast: true, Message: &Message{ID: fmt.Sprint(i), Event: event, Data: "x"}})
+		if err != nil && !errors.Is(err, ErrNoConnections) {
+			t.Fatalf("SendMessage() error = %v", err)
+		}
+	}
+}
+
+func pageIDs(page *HistoryPage) []string {
+	ids := []string{}
+	for _, message := range page.Messages {
+		ids = append(ids, message.ID)
+	}
+	return ids
+}
+
+func TestHub_History(t *testing.T) {
+	hub := NewHub(nil)
+	hub.SetHistory("orders", 8)
+	sendHistory(t, hub, "orders", 10) // 1 and 2 are evicted
+
+	tests := []struct {
+		name      string
+		q         HistoryQuery
+		wantIDs   []string
+		wantOlder string
+		wantNewer string
+		wantLast  string
+		wantErr   error
+	}{
+		{name: "latest", q: HistoryQuery{Limit: 3}, wantIDs: []string{"8", "9", "10"}, wantOlder: "8", wantLast: "10"},
+		{name: "before", q: HistoryQuery{Before: "8", Limit: 3}, wantIDs: []string{"5", "6", "7"}, wantOlder: "5", wantNewer: "7", wantLast: "7"},
+		{name: "first page", q: HistoryQuery{Before: "5"}, wantIDs: []string{"3", "4"}, wantNewer: "4", wantLast: "4"},
+		{name: "after", q: HistoryQuery{After: "4", Limit: 2}, wantIDs: []string{"5", "6"}, wantOlder: "5", wantNewer: "6", wantLast: "6"},
+		{name: "after to head", q: HistoryQuery{After: "8"}, wantIDs: []string{"9", "10"}, wantOlder: "9", wantLast: "10"},
+		{name: "event filter", q: HistoryQuery{Events: []string{"a"}, Limit: 2}, wantIDs: []string{"7", "9"}, wantOlder: "7", wantLast: "10"},
+		{name: "event filter after", q: HistoryQuery{After: "3", Events: []string{"b"}, Limit: 2}, wantIDs: []string{"4", "6"}, wantNewer: "6", wantLast: "7"},
+		{name: "evicted cursor", q: HistoryQuery{After: "2"}, wantErr: ErrCursorNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := hub.History("orders", tt.q)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("History() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("History() error = %v", err)
+			}
+			if got := pageIDs(page); !reflect.DeepEqual(got, tt.wantIDs) {
+				t.Fatalf("messages = %v, want %v", got, tt.wantIDs)
+			}
+			if page.Older != tt.wantOlder || page.Newer != tt.wantNewer || page.LastEventID != tt.wantLast {
+				t.Fatalf("older/newer/last = %q/%q/%q, want %q/%q/%q", page.Older, page.Newer, page.LastEventID, tt.wantOlder, tt.wantNewer, tt.wantLast)
+			}
+		})
+	}
+
+	if _, err := hub.History("chat", HistoryQuery{}); !errors.Is(err, ErrHistoryDisabled) {
+		t.Fatalf("History() error = %v, want ErrHistoryDisabled", err)
+	}
+	hub.SetHistory("orders", 2)
+	if page, _ := hub.History("orders", HistoryQuery{}); !reflect.DeepEqual(pageIDs(page), []string{"9", "10"}) {
+		t.Fatalf("messages after shrink = %v", pageIDs(page))
+	}
+}
+
+func TestHub_HistoryAssignsID(t *testing.T) {
+	hub := NewHub(nil)
+	hub.SetHistory("default", 10)
+	ch := make(chan *Message, 1)
+	addLinks(hub, "default", map[string]Link{"client": {messageChan: ch, allowPush: make(chan struct{})}})
+
+	message := &Message{Event: "a", Data: "x"}
+	if err := hub.SendMessage(Packet{Zone: "default", Broadcast: true, Message: message}); err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+	var live *Message
+	select {
+	case live = <-ch:
+	case <-time.After(time.Second):
+		t.Fatal("message was not delivered")
+	}
+	page, _ := hub.History("default", HistoryQuery{})
+	if len(page.Messages) != 1 || live.ID == "" || page.Messages[0].ID != live.ID {
+		t.Fatalf("history %v and live ID %q differ", pageIDs(page), live.ID)
+	}
+	if message.ID != "" {
+		t.Fatalf("caller message ID = %q, want unchanged", message.ID)
+	}
+}
+
+func TestHub_HistoryHandler(t *testing.T) {
+	hub := NewHub(nil)
+	hub.SetHistory("orders", 10)
+	sendHistory(t, hub, "orders", 5)
+	handler := hub.HistoryHandler(WithZoneFromPath("/history/"))
+
+	tests := []struct {
+		name       string
+		target     string
+		lastID     string
+		wantStatus int
+		wantIDs    []string
+	}{
+		{name: "latest", target: "/history/orders?limit=2", wantStatus: http.StatusOK, wantIDs: []string{"4", "5"}},
+		{name: "events", target: "/history/orders?event=b&event=c,d", wantStatus: http.StatusOK, wantIDs: []string{"2", "4"}},
+		{name: "last event id", target: "/history/orders", lastID: "3", wantStatus: http.StatusOK, wantIDs: []string{"4", "5"}},
+		{name: "before", target: "/history/orders?before=3", wantStatus: http.StatusOK, wantIDs: []string{"1", "2"}},
+		{name: "invalid limit", target: "/history/orders?limit=x", wantStatus: http.StatusBadRequest},
+		{name: "unknown cursor", target: "/history/orders?after=99", wantStatus: http.StatusGone},
+		{name: "disabled zone", target: "/history/chat", wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.lastID != "" {
+				req.Header.Set("Last-Event-ID", tt.lastID)
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", recorder.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			page := &HistoryPage{}
+			if err := json.NewDecoder(recorder.Body).Decode(page); err != nil {
+				t.Fatalf("decode error = %v", err)
+			}
+			if got := pageIDs(page); !reflect.DeepEqual(got, tt.wantIDs) {
+				t.Fatalf("messages = %v, want %v", got, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestHub_RegisterBlockLastEventID(t *testing.T) {
+	hub := NewHub(nil)
+	hub.SetHistory("default", 10)
+	sendHistory(t, hub, "default", 4)
+
+	server := httptest.NewServer(hub.Handler())
+	defer server.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	req.Header.Set("Last-Event-ID", "2")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	var ids []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() && len(ids) < 3 {
+		if id := strings.TrimPrefix(scanner.Text(), "id: "); id != scanner.Text() {
+			ids = append(ids, id)
+		}
+	}
+	// the connected message carries the newest retained ID
+	if len(ids) != 3 || ids[0] != "4" || ids[1] != "3" || ids[2] != "4" {
+		t.Fatalf("ids = %v, want connected message then 3 and 4", ids)
+	}
+}
@@ -0,0 +1,116 @@
+package sse
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	charset       = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	crockford     = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+	defaultIDSize = 16
+	maxIDAttempts = 16 //生成ID与在线连接冲突时的最大重试次数
+)
+
+var (
+	ulidMu   sync.Mutex
+	ulidLast [16]byte //上一个 ULID,同一毫秒内递增以保证有序
+)
+
+// IDGenerator generates connection IDs for the Hub (Hub.IDGenerator)
+type IDGenerator func() string
+
+// RandomID crypto-random alphanumeric IDs of length characters
+func RandomID(length int) IDGenerator {
+	return func() string {
+		b := make([]byte, length)
+		readRandom(b)
+		for i := range b {
+			// 256 % 62 bias is negligible for connection IDs
+			b[i] = charset[int(b[i])%len(charset)]
+		}
+		return string(b)
+	}
+}
+
+// UUIDv4 random UUID as specified in RFC 4122, e.g. 9b2f6c1e-8f3a-4c2d-9e7b-1a2b3c4d5e6f
+func UUIDv4() string {
+	var u [16]byte
+	readRandom(u[:])
+	u[6] = (u[6] & 0x0f) | 0x40
+	u[8] = (u[8] & 0x3f) | 0x80
+	var b [36]byte
+	hex.Encode(b[0:8], u[0:4])
+	hex.Encode(b[9:13], u[4:6])
+	hex.Encode(b[14:18], u[6:8])
+	hex.Encode(b[19:23], u[8:10])
+	hex.Encode(b[24:], u[10:])
+	b[8], b[13], b[18], b[23] = '-', '-', '-', '-'
+	return string(b[:])
+}
+
+// ULID time-sortable ID: 48 bit millisecond timestamp and 80 random bits in Crockford base32 (26 characters),
+// IDs generated within the same millisecond are monotonically increasing
+func ULID() string {
+	var u [16]byte
+	ms := uint64(time.Now().UnixNano() / int64(time.Millisecond))
+	binary.BigEndian.PutUint16(u[0:2], uint16(ms>>32))
+	binary.BigEndian.PutUint32(u[2:6], uint32(ms))
+
+	ulidMu.Lock()
+	if string(u[:6]) == string(ulidLast[:6]) {
+		copy(u[6:], ulidLast[6:])
+		for i := 15; i >= 6; i-- {
+			u[i]++
+			if u[i] != 0 {
+				break
+			}
+		}
+	} else {
+		readRandom(u[6:])
+	}
+	ulidLast = u
+	ulidMu.Unlock()
+
+	// 128 bits -> 26 base32 characters, the first one holds the top 3 bits
+	var b [26]byte
+	hi := binary.BigEndian.Uint64(u[0:8])
+	lo := binary.BigEndian.Uint64(u[8:16])
+	for i := 25; i >= 0; i-- {
+		b[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(b[:])
+}
+
+// readRandom fill b from crypto/rand
+func readRandom(b []byte) {
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("sse: crypto/rand failed: %v", err))
+	}
+}
+
+// getClientID generate a connection ID with IDGenerator (RandomID(16) by default)
+// that is not used by any live connection
+func (hub *Hub) getClientID() string {
+	generate := hub.IDGenerator
+	if generate == nil {
+		generate = RandomID(defaultIDSize)
+	}
+	var id string
+	for i := 0; i < maxIDAttempts; i++ {
+		id = generate()
+		if _, _, exists := hub.cons.find(id); !exists {
+			return id
+		}
+	}
+	if hub.log != nil {
+		hub.log.Warn(fmt.Sprintf("IDGenerator returned live connection ID %s %d times", id, maxIDAttempts))
+	}
+	return id
+}
@@ -0,0 +1,70 @@
+package sse
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestUUIDv4(t *testing.T) {
+	pattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	seen := make(map[string]struct{})
+	for i := 0; i < 100; i++ {
+		id := UUIDv4()
+		if !pattern.MatchString(id) {
+			t.Fatalf("UUIDv4() = %q, not a version 4 UUID", id)
+		}
+		if _, ok := seen[id]; ok {
+			t.Fatalf("UUIDv4() duplicate %q", id)
+		}
+		seen[id] = struct{}{}
+	}
+}
+
+func TestULID(t *testing.T) {
+	pattern := regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`)
+	prev := ULID()
+	for i := 0; i < 1000; i++ {
+		id := ULID()
+		if !pattern.MatchString(id) {
+			t.Fatalf("ULID() = %q, not a ULID", id)
+		}
+		if id <= prev {
+			t.Fatalf("ULID() %q is not greater than %q", id, prev)
+		}
+		prev = id
+	}
+}
+
+func TestRandomID(t *testing.T) {
+	pattern := regexp.MustCompile(`^[a-zA-Z0-9]{24}$`)
+	if id := RandomID(24)(); !pattern.MatchString(id) {
+		t.Fatalf("RandomID() = %q", id)
+	}
+}
+
+func TestHub_getClientIDLiveConflict(t *testing.T) {
+	hub := NewHub(&mockLog{})
+	addLinks(hub, "zone", map[string]Link{"taken": {}})
+	ids := []string{"taken", "taken", "free"}
+	hub.IDGenerator = func() string {
+		id := ids[0]
+		ids = ids[1:]
+		return id
+	}
+	if got := hub.getClientID(); got != "free" {
+		t.Fatalf("getClientID() = %q, want free", got)
+	}
+
+	hub.IDGenerator = func() string {
+		return "taken"
+	}
+	if got := hub.getClientID(); got != "taken" {
+		t.Fatalf("getClientID() = %q, want taken after max attempts", got)
+	}
+
+	// IDs of closed connections can be reused
+	hub.UnRegisterBlock("zone", "taken")
+	if got := hub.getClientID(); got != "taken" {
+		t.Fatalf("getClientID() = %q, want taken", got)
+	}
+}
@@ -0,0 +1,56 @@
+package sse
+
+import (
+	"errors"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// ErrIdleTimeout nothing arrived on the stream within the idle timeout of the client
+var ErrIdleTimeout = errors.New("sse: no data received within the idle timeout")
+
+// SetIdleTimeout reconnect when no bytes (messages, comments or heartbeats) arrive within d,
+// e.g. after a NAT silently dropped the connection. It should be well above the heartbeat interval
+// of the server, 0 disables it (default). Only the time spent waiting for the server counts,
+// not the time reading is paused for a slow consumer (Events, SetSequential)
+func (c *Client) SetIdleTimeout(d time.Duration) {
+	c.idleTimeout = d
+}
+
+// idleReader close body when a Read waits longer than timeout, the read then fails with ErrIdleTimeout
+type idleReader struct {
+	body    io.ReadCloser
+	timeout time.Duration
+	timer   *time.Timer
+	expired int32
+}
+
+func newIdleReader(body io.ReadCloser, timeout time.Duration) *idleReader {
+	r := &idleReader{body: body, timeout: timeout}
+	// the timer runs while a Read is pending
+	r.timer = time.AfterFunc(timeout, r.expire)
+	r.timer.Stop()
+	return r
+}
+
+// expire tear down the connection, closing the body unblocks the pending Read
+func (r *idleReader) expire() {
+	atomic.StoreInt32(&r.expired, 1)
+	_ = r.body.Close()
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+	r.timer.Reset(r.timeout)
+	n, err := r.body.Read(p)
+	r.timer.Stop()
+	if err != nil && atomic.LoadInt32(&r.expired) == 1 {
+		return n, ErrIdleTimeout
+	}
+	return n, err
+}
+
+func (r *idleReader) Close() error {
+	r.timer.Stop()
+	return r.body.Close()
+}
@@ -0,0 +1,123 @@
+package sse
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClient_SetIdleTimeout(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: hello\n\n")
+		w.(http.Flusher).Flush()
+		// the connection stays open but goes silent
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, http.MethodGet, time.Millisecond)
+	client.SetIdleTimeout(50 * time.Millisecond)
+	disconnected := make(chan string, 4)
+	client.OnDisconnect(func(reason string) {
+		disconnected <- reason
+	})
+	go client.Start()
+	defer client.Stop()
+
+	select {
+	case reason := <-disconnected:
+		if reason != ErrIdleTimeout.Error() {
+			t.Fatalf("disconnect reason = %q, want %q", reason, ErrIdleTimeout.Error())
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("idle connection was not torn down")
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(&requests) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("client did not reconnect after the idle timeout")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestClient_SetIdleTimeoutHeartbeat(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			// comments keep the stream alive without dispatching messages
+			fmt.Fprint(w, ": heartbeat\n\n")
+			w.(http.Flusher).Flush()
+			select {
+			case <-ticker.C:
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, http.MethodGet, time.Millisecond)
+	client.SetIdleTimeout(50 * time.Millisecond)
+	disconnected := make(chan string, 1)
+	client.OnDisconnect(func(reason string) {
+		disconnected <- reason
+	})
+	go client.Start()
+	defer client.Stop()
+
+	select {
+	case reason := <-disconnected:
+		t.Fatalf("disconnected (%s) although heartbeats arrived", reason)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestClient_SetIdleTimeoutBackpressure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		ticker := time.NewTicker(20 * time.Millisecond)
+		defer ticker.Stop()
+		for i := 0; ; i++ {
+			fmt.Fprintf(w, "id: %d\ndata: x\n\n", i)
+			w.(http.Flusher).Flush()
+			select {
+			case <-ticker.C:
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, http.MethodGet, time.Millisecond)
+	client.SetIdleTimeout(200 * time.Millisecond)
+	disconnected := make(chan string, 1)
+	client.OnDisconnect(func(reason string) {
+		disconnected <- reason
+	})
+	events := client.Events()
+	go client.Start()
+	defer client.Stop()
+
+	<-events
+	// the full Events channel pauses reading far longer than the idle timeout
+	time.Sleep(time.Second)
+	go func() {
+		for range events {
+		}
+	}()
+	select {
+	case reason := <-disconnected:
+		t.Fatalf("disconnected (%s) while the consumer was slow", reason)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
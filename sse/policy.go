@@ -0,0 +1,187 @@
+package sse
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrPublisherDenied Packet.Publisher may not publish into the zone
+	ErrPublisherDenied = errors.New("publisher not allowed")
+	// ErrEventDenied the event name is not allowed in the zone
+	ErrEventDenied = errors.New("event not allowed")
+	// ErrInvalidMessage the message does not pass the validator or schema of its event
+	ErrInvalidMessage = errors.New("invalid message")
+)
+
+// ZonePolicy publish rules of a zone, see Hub.SetZonePolicy
+type ZonePolicy struct {
+	Publishers []string             //允许发布的 Packet.Publisher,为空不限制
+	Events     map[string]EventRule //允许的事件名称及其校验规则,为空不限制
+}
+
+// EventRule validation of an event, both Validate and Schema are optional
+//
+// Schema is a simple JSON shape of Message.Data: field name -> type, where type is one of
+// string, number, bool, object, array or any, a "?" suffix marks the field optional, e.g.
+//
+//	EventRule{Schema: map[string]string{"order_id": "string", "amount": "number", "note": "string?"}}
+type EventRule struct {
+	Validate func(message *Message) error
+	Schema   map[string]string
+}
+
+// PolicyError SendMessage was rejected by the zone policy, Err is one of
+// ErrPublisherDenied, ErrEventDenied or ErrInvalidMessage (use errors.Is)
+type PolicyError struct {
+	Zone      string
+	Event     string
+	Publisher string
+	Err       error
+	Cause     error //校验失败的原因,可为空
+}
+
+func (e *PolicyError) Error() string {
+	msg := fmt.Sprintf("zone %s: publisher %q event %q: %s", e.Zone, e.Publisher, e.Event, e.Err)
+	if e.Cause != nil {
+		msg += ": " + e.Cause.Error()
+	}
+	return msg
+}
+
+func (e *PolicyError) Unwrap() error {
+	return e.Err
+}
+
+// SetZonePolicy set the publish rules of zone, nil removes them.
+// a broadcast to all zones must pass the policy of every zone
+func (hub *Hub) SetZonePolicy(zone string, policy *ZonePolicy) {
+	hub.zblock.Lock()
+	defer hub.zblock.Unlock()
+	if policy == nil {
+		delete(hub.policies, zone)
+		return
+	}
+	hub.policies[zone] = policy
+}
+
+// checkPolicy validate pkg against the policy of its zone (every zone for all broadcasts)
+func (hub *Hub) checkPolicy(pkg Packet) error {
+	hub.zblock.RLock()
+	defer hub.zblock.RUnlock()
+	if len(hub.policies) == 0 {
+		return nil
+	}
+	if pkg.Zone != "" {
+		if policy, ok := hub.policies[pkg.Zone]; ok {
+			return policy.check(pkg.Zone, pkg)
+		}
+		return nil
+	}
+	if !pkg.Broadcast {
+		return nil
+	}
+	zones := make([]string, 0, len(hub.policies))
+	for zone := range hub.policies {
+		zones = append(zones, zone)
+	}
+	sort.Strings(zones)
+	for _, zone := range zones {
+		if err := hub.policies[zone].check(zone, pkg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// check validate pkg against the policy
+func (p *ZonePolicy) check(zone string, pkg Packet) error {
+	event := ""
+	if pkg.Message != nil {
+		event = pkg.Message.Event
+	}
+	fail := func(err, cause error) error {
+		return &PolicyError{Zone: zone, Event: event, Publisher: pkg.Publisher, Err: err, Cause: cause}
+	}
+	if len(p.Publishers) > 0 && !containsString(p.Publishers, pkg.Publisher) {
+		return fail(ErrPublisherDenied, nil)
+	}
+	if len(p.Events) == 0 {
+		return nil
+	}
+	rule, ok := p.Events[event]
+	if !ok {
+		return fail(ErrEventDenied, nil)
+	}
+	if pkg.Message == nil {
+		return fail(ErrInvalidMessage, errors.New("message is nil"))
+	}
+	if rule.Schema != nil {
+		if err := validateSchema(pkg.Message.Data, rule.Schema); err != nil {
+			return fail(ErrInvalidMessage, err)
+		}
+	}
+	if rule.Validate != nil {
+		if err := rule.Validate(pkg.Message); err != nil {
+			return fail(ErrInvalidMessage, err)
+		}
+	}
+	return nil
+}
+
+// validateSchema check data is a JSON object matching schema
+func validateSchema(data string, schema map[string]string) error {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(data), &object); err != nil {
+		return fmt.Errorf("data is not a json object: %w", err)
+	}
+	fields := make([]string, 0, len(schema))
+	for field := range schema {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		kind := schema[field]
+		optional := strings.HasSuffix(kind, "?")
+		kind = strings.TrimSuffix(kind, "?")
+		raw, ok := object[field]
+		if !ok || string(raw) == "null" {
+			if optional {
+				continue
+			}
+			return fmt.Errorf("field %s is required", field)
+		}
+		if got := jsonKind(raw); kind != "any" && got != kind {
+			return fmt.Errorf("field %s is %s, want %s", field, got, kind)
+		}
+	}
+	return nil
+}
+
+// jsonKind the schema type of a JSON value
+func jsonKind(raw json.RawMessage) string {
+	switch raw[0] {
+	case '"':
+		return "string"
+	case '{':
+		return "object"
+	case '[':
+		return "array"
+	case 't', 'f':
+		return "bool"
+	default:
+		return "number"
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
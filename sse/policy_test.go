@@ -0,0 +1,118 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestHub_SendMessagePolicy(t *testing.T) {
+	hub := NewHub(nil)
+	addLinks(hub, "orders", map[string]Link{
+		"client": {messageChan: make(chan *Message, 10), allowPush: make(chan struct{})},
+	})
+	addLinks(hub, "chat", map[string]Link{
+		"other": {messageChan: make(chan *Message, 10), allowPush: make(chan struct{})},
+	})
+	hub.SetZonePolicy("orders", &ZonePolicy{
+		Publishers: []string{"order-service"},
+		Events: map[string]EventRule{
+			"created": {Schema: map[string]string{"order_id": "string", "amount": "number", "tags": "array?"}},
+			"shipped": {Validate: func(message *Message) error {
+				if !strings.HasPrefix(message.Data, "SHP-") {
+					return errors.New("tracking number must start with SHP-")
+				}
+				return nil
+			}},
+			"ping": {},
+		},
+	})
+
+	tests := []struct {
+		name    string
+		pkg     Packet
+		wantErr error
+	}{
+		{
+			name: "valid schema",
+			pkg:  Packet{Zone: "orders", Broadcast: true, Publisher: "order-service", Message: &Message{Event: "created", Data: `{"order_id":"1","amount":9.5}`}},
+		},
+		{
+			name: "valid validator",
+			pkg:  Packet{Zone: "orders", ClientID: "client", Publisher: "order-service", Message: &Message{Event: "shipped", Data: "SHP-1"}},
+		},
+		{
+			name:    "publisher denied",
+			pkg:     Packet{Zone: "orders", Broadcast: true, Publisher: "chat-service", Message: &Message{Event: "ping", Data: "x"}},
+			wantErr: ErrPublisherDenied,
+		},
+		{
+			name:    "event denied",
+			pkg:     Packet{Zone: "orders", Broadcast: true, Publisher: "order-service", Message: &Message{Event: "deleted", Data: "x"}},
+			wantErr: ErrEventDenied,
+		},
+		{
+			name:    "missing field",
+			pkg:     Packet{Zone: "orders", Broadcast: true, Publisher: "order-service", Message: &Message{Event: "created", Data: `{"order_id":"1"}`}},
+			wantErr: ErrInvalidMessage,
+		},
+		{
+			name:    "wrong type",
+			pkg:     Packet{Zone: "orders", Broadcast: true, Publisher: "order-service", Message: &Message{Event: "created", Data: `{"order_id":1,"amount":1}`}},
+			wantErr: ErrInvalidMessage,
+		},
+		{
+			name:    "not json",
+			pkg:     Packet{Zone: "orders", Broadcast: true, Publisher: "order-service", Message: &Message{Event: "created", Data: "hello"}},
+			wantErr: ErrInvalidMessage,
+		},
+		{
+			name:    "validator error",
+			pkg:     Packet{Zone: "orders", ClientID: "client", Publisher: "order-service", Message: &Message{Event: "shipped", Data: "1Z"}},
+			wantErr: ErrInvalidMessage,
+		},
+		{
+			name:    "all broadcast checks every zone",
+			pkg:     Packet{Broadcast: true, Message: &Message{Event: "ping", Data: "x"}},
+			wantErr: ErrPublisherDenied,
+		},
+		{
+			name: "zone without policy",
+			pkg:  Packet{Zone: "chat", Broadcast: true, Message: &Message{Event: "anything", Data: "x"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := hub.SendMessage(tt.pkg)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("SendMessage() error = %v", err)
+				}
+				return
+			}
+			var policyErr *PolicyError
+			if !errors.Is(err, tt.wantErr) || !errors.As(err, &policyErr) {
+				t.Fatalf("SendMessage() error = %v, want %v", err, tt.wantErr)
+			}
+			if policyErr.Zone != "orders" {
+				t.Fatalf("PolicyError.Zone = %q", policyErr.Zone)
+			}
+		})
+	}
+
+	if _, err := hub.SendMessageContext(context.Background(), Packet{Zone: "orders", Broadcast: true, Message: &Message{Event: "ping", Data: "x"}}); !errors.Is(err, ErrPublisherDenied) {
+		t.Fatalf("SendMessageContext() error = %v, want ErrPublisherDenied", err)
+	}
+	hub.SetZonePolicy("orders", nil)
+	if err := hub.SendMessage(Packet{Zone: "orders", Broadcast: true, Message: &Message{Event: "deleted", Data: "x"}}); err != nil {
+		t.Fatalf("SendMessage() after removing policy error = %v", err)
+	}
+}
+
+func TestPolicyError_Error(t *testing.T) {
+	err := &PolicyError{Zone: "z", Event: "e", Publisher: "p", Err: ErrInvalidMessage, Cause: errors.New("bad")}
+	if got := err.Error(); got != `zone z: publisher "p" event "e": invalid message: bad` {
+		t.Fatalf("Error() = %q", got)
+	}
+}
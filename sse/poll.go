@@ -0,0 +1,287 @@
+package sse
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultPollTimeout     = 25 * time.Second
+	defaultPollIdleTimeout = 60 * time.Second
+	pollQueueSize          = 1024 //单个长轮询会话最多缓存的未确认消息数
+)
+
+// PollResponse long-polling response body
+// Cursor is the sequence of the last message returned, send it back on the next poll
+type PollResponse struct {
+	ClientID string     `json:"client_id"`
+	Cursor   uint64     `json:"cursor"`
+	Messages []*Message `json:"messages"`
+}
+
+// pollSession virtual Link of a long-polling client
+// messages written to the Link are queued until the client acknowledges them with a cursor
+type pollSession struct {
+	zone   string
+	id     string
+	link   Link
+	seq    uint64        //最后一条消息的序号
+	queue  []pollEntry   //未确认的消息
+	notify chan struct{} //有新消息时关闭
+	active int           //正在等待的请求数
+	expire *time.Timer
+	done   chan struct{}
+	closed bool //done 已关闭
+}
+
+// pollEntry queued message with its sequence
+type pollEntry struct {
+	seq     uint64
+	key     string //合并键
+	message *Message
+}
+
+// RegisterPoll long-polling fallback of RegisterBlock
+// the first request creates a virtual Link in the zone and returns its client_id,
+// following requests must carry ?id=<client_id>&cursor=<cursor> to resume without loss.
+// Responds as soon as messages are queued, or with an empty list after PollTimeout
+func (hub *Hub) RegisterPoll(w http.ResponseWriter, r *http.Request, zone string, uuid func() string) {
+	if zone == "" {
+		zone = "default"
+	}
+	var cursor uint64
+	if c := r.FormValue("cursor"); c != "" {
+		var err error
+		if cursor, err = strconv.ParseUint(c, 10, 64); err != nil {
+			http.Error(w, "invalid cursor", http.StatusBadRequest)
+			return
+		}
+	}
+	id := r.FormValue("id")
+	s := hub.acquirePoll(zone, id, uuid)
+	defer hub.releasePoll(s)
+	if s.id != id {
+		// new session, the cursor belongs to an expired one
+		cursor = 0
+	}
+
+	messages, last, ok := hub.waitPoll(r.Context(), s, cursor)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	err := json.NewEncoder(w).Encode(PollResponse{ClientID: s.id, Cursor: last, Messages: messages})
+	if err != nil && hub.log != nil {
+		hub.log.Error(fmt.Sprintf("push poll messages to client err:%+v\n", err.Error()))
+	}
+}
+
+// acquirePoll find the session of the id in zone, or create a new one
+// the session will not expire until releasePoll is called
+func (hub *Hub) acquirePoll(zone, id string, uuid func() string) *pollSession {
+	hub.pblock.Lock()
+	if s, ok := hub.polls[pollKey(zone, id)]; ok && id != "" {
+		s.active++
+		s.expire.Stop()
+		hub.pblock.Unlock()
+		return s
+	}
+	if uuid == nil {
+		uuid = func() string {
+			return hub.getClientID()
+		}
+	}
+	newID := uuid()
+	if s, ok := hub.polls[pollKey(zone, newID)]; ok {
+		// uuid returns stable IDs, e.g. derived from the user, resume that session
+		s.active++
+		s.expire.Stop()
+		hub.pblock.Unlock()
+		return s
+	}
+	s := hub.newPollSession(zone, newID)
+	s.active = 1
+	s.expire.Stop()
+	s.push(hub.connectedMessage(zone, s.id), "")
+	hub.polls[pollKey(zone, s.id)] = s
+	hub.pblock.Unlock()
+
+	hub.startPoll(s)
+	if hub.ConnectedFunc != nil {
+		hub.ConnectedFunc(s.id)
+	}
+	return s
+}
+
+// newPollSession create a session whose idle timer is already running
+func (hub *Hub) newPollSession(zone, id string) *pollSession {
+	s := &pollSession{
+		zone:   zone,
+		id:     id,
+		link:   Link{messageChan: make(chan *Message, pollQueueSize), allowPush: make(chan struct{}), pending: newCoalescer(), createTime: time.Now().Unix()},
+		notify: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	s.expire = time.AfterFunc(hub.pollIdleTimeout(), func() {
+		hub.expirePoll(s)
+	})
+	return s
+}
+
+// startPoll register the virtual Link of s so messages reach it
+func (hub *Hub) startPoll(s *pollSession) {
+	hub.cons.add(s.zone, s.id, s.link)
+	go hub.drainPoll(s)
+}
+
+// releasePoll the request has finished, start counting the idle timeout
+func (hub *Hub) releasePoll(s *pollSession) {
+	hub.pblock.Lock()
+	defer hub.pblock.Unlock()
+	s.active--
+	if s.active == 0 {
+		s.expire.Reset(hub.pollIdleTimeout())
+	}
+}
+
+// expirePoll unregister the virtual Link after no request arrived in PollIdleTimeout
+func (hub *Hub) expirePoll(s *pollSession) {
+	hub.pblock.Lock()
+	if s.active > 0 || s.closed {
+		hub.pblock.Unlock()
+		return
+	}
+	s.closed = true
+	close(s.done)
+	if hub.polls[pollKey(s.zone, s.id)] != s {
+		// replaced by a newer session with the same ID, which owns the Link now
+		hub.pblock.Unlock()
+		return
+	}
+	delete(hub.polls, pollKey(s.zone, s.id))
+	hub.pblock.Unlock()
+
+	replaced := !hub.cons.removeLink(s.zone, s.id, s.link)
+	if hub.log != nil {
+		hub.log.Info(fmt.Sprintf("%s:%s poll session expired", s.zone, s.id))
+	}
+	if !replaced && hub.DisconnectFunc != nil {
+		hub.DisconnectFunc(s.id)
+	}
+}
+
+// drainPoll move messages written to the virtual Link into the session queue
+func (hub *Hub) drainPoll(s *pollSession) {
+	for {
+		select {
+		case message := <-s.link.messageChan:
+			hub.queuePoll(s, coalesced{message: message})
+		case <-s.link.pending.ready:
+			for _, c := range s.link.pending.take() {
+				hub.queuePoll(s, c)
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// queuePoll queue a message written to the virtual Link
+func (hub *Hub) queuePoll(s *pollSession, c coalesced) {
+	hub.pblock.Lock()
+	dropped := s.push(c.message, c.key)
+	hub.pblock.Unlock()
+	if dropped && hub.log != nil {
+		hub.log.Warn(fmt.Sprintf("%s:%s poll queue is full, oldest message dropped", s.zone, s.id))
+	}
+}
+
+// waitPoll acknowledge messages up to cursor and wait for newer ones
+// ok is false when the request context is done
+func (hub *Hub) waitPoll(ctx context.Context, s *pollSession, cursor uint64) ([]*Message, uint64, bool) {
+	timer := time.NewTimer(hub.pollTimeout())
+	defer timer.Stop()
+	for {
+		hub.pblock.Lock()
+		s.ack(cursor)
+		if len(s.queue) > 0 {
+			messages := make([]*Message, 0, len(s.queue))
+			for _, e := range s.queue {
+				messages = append(messages, e.message)
+			}
+			last := s.queue[len(s.queue)-1].seq
+			hub.pblock.Unlock()
+			return messages, last, true
+		}
+		last, notify := s.seq, s.notify
+		hub.pblock.Unlock()
+
+		select {
+		case <-notify:
+		case <-timer.C:
+			return []*Message{}, last, true
+		case <-s.done:
+			return []*Message{}, last, true
+		case <-ctx.Done():
+			return nil, 0, false
+		}
+	}
+}
+
+// push queue a message and wake up waiting requests, reports whether the oldest message was dropped
+// a pending message with the same coalesce key is replaced by the new one
+func (s *pollSession) push(message *Message, key string) bool {
+	if key != "" {
+		for i, e := range s.queue {
+			if e.key == key {
+				s.queue = append(s.queue[:i], s.queue[i+1:]...)
+				break
+			}
+		}
+	}
+	s.seq++
+	s.queue = append(s.queue, pollEntry{seq: s.seq, key: key, message: message})
+	dropped := len(s.queue) > pollQueueSize
+	if dropped {
+		s.queue = s.queue[1:]
+	}
+	close(s.notify)
+	s.notify = make(chan struct{})
+	return dropped
+}
+
+// ack drop the messages the client has received
+// a cursor beyond the last sequence (e.g. from an expired session) is treated as the last sequence
+func (s *pollSession) ack(cursor uint64) {
+	if cursor > s.seq {
+		cursor = s.seq
+	}
+	i := 0
+	for i < len(s.queue) && s.queue[i].seq <= cursor {
+		i++
+	}
+	s.queue = s.queue[i:]
+}
+
+func (hub *Hub) pollTimeout() time.Duration {
+	if hub.PollTimeout > 0 {
+		return hub.PollTimeout
+	}
+	return defaultPollTimeout
+}
+
+func (hub *Hub) pollIdleTimeout() time.Duration {
+	if hub.PollIdleTimeout > 0 {
+		return hub.PollIdleTimeout
+	}
+	return defaultPollIdleTimeout
+}
+
+func pollKey(zone, id string) string {
+	return zone + "\x00" + id
+}
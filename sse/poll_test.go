@@ -0,0 +1,206 @@
+package sse
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func poll(t *testing.T, hub *Hub, query string) PollResponse {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/poll?"+query, nil)
+	recorder := httptest.NewRecorder()
+	hub.RegisterPoll(recorder, req, "zone", nil)
+	var resp PollResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("RegisterPoll() body = %q, err = %v", recorder.Body.String(), err)
+	}
+	return resp
+}
+
+func TestHub_RegisterPoll(t *testing.T) {
+	hub := NewHub(nil)
+	hub.PollTimeout = 20 * time.Millisecond
+
+	first := poll(t, hub, "")
+	if first.ClientID == "" || len(first.Messages) != 1 || first.Messages[0].Event != "ping" {
+		t.Fatalf("first poll = %+v, want connected ping", first)
+	}
+
+	err := hub.SendMessage(Packet{
+		Message:  &Message{Event: "event", Data: "direct"},
+		Zone:     "zone",
+		ClientID: first.ClientID,
+	})
+	if err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+	if err = hub.SendMessage(Packet{Message: &Message{Event: "event", Data: "zone"}, Zone: "zone", Broadcast: true}); err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+
+	var second PollResponse
+	deadline := time.Now().Add(time.Second)
+	for len(second.Messages) < 2 && time.Now().Before(deadline) {
+		second = poll(t, hub, "id="+first.ClientID+"&cursor=1")
+	}
+	if second.ClientID != first.ClientID || len(second.Messages) != 2 {
+		t.Fatalf("second poll = %+v, want 2 messages", second)
+	}
+	if second.Messages[0].Data != "direct" || second.Messages[1].Data != "zone" || second.Cursor != 3 {
+		t.Fatalf("second poll = %+v", second)
+	}
+
+	// a lost response is redelivered when polling with the same cursor
+	retry := poll(t, hub, "id="+first.ClientID+"&cursor=1")
+	if len(retry.Messages) != 2 {
+		t.Fatalf("retry poll = %+v, want redelivery", retry)
+	}
+
+	empty := poll(t, hub, "id="+first.ClientID+"&cursor=3")
+	if len(empty.Messages) != 0 || empty.Cursor != 3 {
+		t.Fatalf("empty poll = %+v", empty)
+	}
+}
+
+func TestHub_RegisterPollWakeUp(t *testing.T) {
+	hub := NewHub(nil)
+	hub.PollTimeout = time.Second
+	first := poll(t, hub, "")
+
+	done := make(chan PollResponse, 1)
+	go func() {
+		done <- poll(t, hub, "id="+first.ClientID+"&cursor=1")
+	}()
+	time.Sleep(10 * time.Millisecond)
+	_ = hub.SendMessage(Packet{Message: &Message{Event: "event", Data: "wake"}, Broadcast: true})
+
+	select {
+	case resp := <-done:
+		if len(resp.Messages) != 1 || resp.Messages[0].Data != "wake" {
+			t.Fatalf("poll = %+v", resp)
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("RegisterPoll() did not return on new message")
+	}
+}
+
+func TestHub_RegisterPollUnknownSession(t *testing.T) {
+	hub := NewHub(nil)
+	hub.PollTimeout = 10 * time.Millisecond
+
+	resp := poll(t, hub, "id=missing&cursor=42")
+	if resp.ClientID == "missing" || len(resp.Messages) != 1 {
+		t.Fatalf("poll = %+v, want new session", resp)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/poll?cursor=abc", nil)
+	recorder := httptest.NewRecorder()
+	hub.RegisterPoll(recorder, req, "", nil)
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", recorder.Code, http.StatusBadRequest)
+	}
+}
+
+func TestHub_RegisterPollCanceled(t *testing.T) {
+	hub := NewHub(nil)
+	first := poll(t, hub, "")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest(http.MethodGet, "/poll?id="+first.ClientID+"&cursor=1", nil).WithContext(ctx)
+	recorder := httptest.NewRecorder()
+	hub.RegisterPoll(recorder, req, "zone", nil)
+	if recorder.Body.Len() != 0 {
+		t.Fatalf("body = %q, want empty", recorder.Body.String())
+	}
+}
+
+func TestHub_RegisterPollExpire(t *testing.T) {
+	hub := NewHub(&mockLog{})
+	hub.PollIdleTimeout = 10 * time.Millisecond
+	disconnected := make(chan string, 1)
+	hub.DisconnectFunc = func(clientID string) {
+		disconnected <- clientID
+	}
+	first := poll(t, hub, "")
+
+	select {
+	case id := <-disconnected:
+		if id != first.ClientID {
+			t.Fatalf("DisconnectFunc id = %v, want %v", id, first.ClientID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("poll session did not expire")
+	}
+	if _, ok := hub.cons.get("zone", first.ClientID); ok {
+		t.Fatal("expired poll session is still registered")
+	}
+}
+
+func TestHub_RegisterPollStableID(t *testing.T) {
+	hub := NewHub(nil)
+	hub.PollTimeout = 10 * time.Millisecond
+	hub.PollIdleTimeout = 20 * time.Millisecond
+	connected := 0
+	hub.ConnectedFunc = func(string) { connected++ }
+	uuid := func() string { return "user-1" }
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/poll", nil)
+		hub.RegisterPoll(httptest.NewRecorder(), req, "zone", uuid)
+	}
+	hub.pblock.Lock()
+	s := hub.polls[pollKey("zone", "user-1")]
+	sessions := len(hub.polls)
+	hub.pblock.Unlock()
+	if sessions != 1 || connected != 1 {
+		t.Fatalf("sessions = %d, ConnectedFunc calls = %d, want the session reused", sessions, connected)
+	}
+	select {
+	case <-s.done:
+	case <-time.After(time.Second):
+		t.Fatal("reused poll session did not expire")
+	}
+}
+
+func TestHub_expirePollReplaced(t *testing.T) {
+	hub := NewHub(nil)
+	old := hub.acquirePoll("zone", "", func() string { return "same" })
+	hub.releasePoll(old)
+	// a newer session took over the key and the Link
+	hub.pblock.Lock()
+	replacement := hub.newPollSession("zone", "same")
+	hub.polls[pollKey("zone", "same")] = replacement
+	hub.pblock.Unlock()
+
+	hub.expirePoll(old)
+	hub.expirePoll(old)
+	select {
+	case <-old.done:
+	default:
+		t.Fatal("replaced session was not closed")
+	}
+	if _, ok := hub.cons.get("zone", "same"); !ok {
+		t.Fatal("expiring the replaced session removed the Link of the new one")
+	}
+	replacement.expire.Stop()
+}
+
+func TestPollSession_push(t *testing.T) {
+	s := &pollSession{notify: make(chan struct{})}
+	for i := 0; i < pollQueueSize; i++ {
+		if s.push(&Message{Data: "data"}, "") {
+			t.Fatal("push() dropped before queue is full")
+		}
+	}
+	if !s.push(&Message{Data: "data"}, "") || len(s.queue) != pollQueueSize || s.queue[0].seq != 2 {
+		t.Fatal("push() did not drop the oldest message")
+	}
+	s.ack(s.seq + 10)
+	if len(s.queue) != 0 {
+		t.Fatal("ack() did not clamp cursor")
+	}
+}
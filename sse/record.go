@@ -0,0 +1,189 @@
+package sse
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RecordedMessage a line of a recording, a message decoded by a Client and when it arrived
+type RecordedMessage struct {
+	Time    time.Time `json:"time"`    //到达时间
+	Message *Message  `json:"message"` //解码后的消息,含注释心跳
+}
+
+// StreamRecorder write every message decoded by a Client to a recording (JSON lines)
+type StreamRecorder struct {
+	mu     sync.Mutex
+	enc    *json.Encoder
+	closer io.Closer
+	err    error //第一次写入错误
+}
+
+// RecordClient tee everything c decodes to w, call it before Start/Run.
+// Handlers installed earlier (Relay, WebhookBridge) keep receiving the messages
+func RecordClient(c *Client, w io.Writer) *StreamRecorder {
+	r := &StreamRecorder{enc: json.NewEncoder(w)}
+	next := c.messageHandler
+	c.messageHandler = func(message *Message) {
+		r.record(message)
+		if next != nil {
+			next(message)
+		}
+	}
+	return r
+}
+
+// RecordClientFile tee everything c decodes to the file path, appending to it
+func RecordClientFile(c *Client, path string) (*StreamRecorder, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	r := RecordClient(c, f)
+	r.closer = f
+	return r, nil
+}
+
+// record append message, recording stops at the first error
+func (r *StreamRecorder) record(message *Message) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+	r.err = r.enc.Encode(&RecordedMessage{Time: time.Now(), Message: message})
+}
+
+// Err the error that stopped the recording
+func (r *StreamRecorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Close stop recording and close the file of RecordClientFile
+func (r *StreamRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err == nil {
+		r.err = io.ErrClosedPipe
+	}
+	if r.closer == nil {
+		return nil
+	}
+	return r.closer.Close()
+}
+
+// ReadRecording parse a recording written by StreamRecorder
+func ReadRecording(reader io.Reader) ([]RecordedMessage, error) {
+	var messages []RecordedMessage
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var m RecordedMessage
+		if err := json.Unmarshal(scanner.Bytes(), &m); err != nil || m.Message == nil {
+			return nil, fmt.Errorf("sse: recording line %d is invalid: %v", line, err)
+		}
+		messages = append(messages, m)
+	}
+	return messages, scanner.Err()
+}
+
+// ReplayHandler serve the recording in path as a live stream, the file is read on every request.
+// speed scales the recorded gaps between messages: 1 is the original timing, 2 twice as fast,
+// 0 sends everything at once. A Last-Event-ID header resumes after that message.
+// The stream stays open after the last message until the client goes away
+func ReplayHandler(path string, speed float64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, err := os.Open(path)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		messages, err := ReadRecording(f)
+		_ = f.Close()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+		messages = resumeRecording(messages, r.Header.Get("Last-Event-ID"))
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		for i, m := range messages {
+			if i > 0 && speed > 0 {
+				gap := time.Duration(float64(m.Time.Sub(messages[i-1].Time)) / speed)
+				if gap > 0 {
+					timer := time.NewTimer(gap)
+					select {
+					case <-timer.C:
+					case <-r.Context().Done():
+						timer.Stop()
+						return
+					}
+				}
+			}
+			if _, err = io.WriteString(w, encodeRecorded(m.Message)); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+		<-r.Context().Done()
+	})
+}
+
+// resumeRecording the messages after the one with id, all of them when id is empty or unknown
+func resumeRecording(messages []RecordedMessage, id string) []RecordedMessage {
+	if id == "" {
+		return messages
+	}
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Message.ID == id {
+			return messages[i+1:]
+		}
+	}
+	return messages
+}
+
+// encodeRecorded the wire format of a decoded message, multi-line data is split into data fields
+func encodeRecorded(m *Message) string {
+	var b strings.Builder
+	if m.Comment != "" {
+		b.WriteString(": " + m.Comment + "\n")
+	}
+	if m.ID != "" {
+		b.WriteString("id: " + m.ID + "\n")
+	}
+	if m.Event != "" {
+		b.WriteString("event: " + m.Event + "\n")
+	}
+	if m.Retry != "" {
+		b.WriteString("retry: " + m.Retry + "\n")
+	}
+	if m.Data != "" {
+		for _, line := range dataLines(m.Text()) {
+			b.WriteString("data: " + line + "\n")
+		}
+	}
+	b.WriteString("\n")
+	return b.String()
+}
@@ -0,0 +1,142 @@
+package sse
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecordClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "id: 1\nevent: order\ndata: line1\ndata: line2\n\n: heartbeat\n\nid: 2\ndata: b\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "stream.jsonl")
+	client := NewClient(server.URL, http.MethodGet, time.Second)
+	forwarded := make(chan *Message, 3)
+	client.messageHandler = func(message *Message) { forwarded <- message }
+	recorder, err := RecordClientFile(client, path)
+	if err != nil {
+		t.Fatalf("RecordClientFile() error = %v", err)
+	}
+	go client.Start()
+	for i := 0; i < 3; i++ {
+		select {
+		case <-forwarded:
+		case <-time.After(2 * time.Second):
+			t.Fatal("the previous message handler was not called")
+		}
+	}
+	client.Stop()
+	if err = recorder.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	f, _ := os.Open(path)
+	defer f.Close()
+	recording, err := ReadRecording(f)
+	if err != nil {
+		t.Fatalf("ReadRecording() error = %v", err)
+	}
+	if len(recording) != 3 {
+		t.Fatalf("recorded %d messages, want 3", len(recording))
+	}
+	if m := recording[0].Message; m.ID != "1" || m.Event != "order" || m.Data != "line1\nline2\n" || recording[0].Time.IsZero() {
+		t.Fatalf("recording[0] = %+v", recording[0])
+	}
+	if recording[1].Message.Comment != "heartbeat" {
+		t.Fatalf("recording[1] = %+v, want the heartbeat", recording[1].Message)
+	}
+}
+
+func TestReadRecordingInvalid(t *testing.T) {
+	if _, err := ReadRecording(bytes.NewBufferString("{\"time\":\"2024-01-01T00:00:00Z\"}\n")); err == nil {
+		t.Fatal("ReadRecording() want error for a line without message")
+	}
+}
+
+// writeRecording write messages sent gap apart to a temporary file
+func writeRecording(t *testing.T, gap time.Duration, messages ...*Message) string {
+	t.Helper()
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	start := time.Now()
+	for i, m := range messages {
+		_ = enc.Encode(&RecordedMessage{Time: start.Add(time.Duration(i) * gap), Message: m})
+	}
+	path := filepath.Join(t.TempDir(), "stream.jsonl")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// replay connect to the replay handler and return the messages with data and how long they took
+func replay(t *testing.T, handler http.Handler, lastEventID string, n int) ([]*Message, time.Duration) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	client := NewClient(server.URL, http.MethodGet, time.Second)
+	client.SetLastEventID(lastEventID)
+	events := client.Events()
+	start := time.Now()
+	go client.Start()
+	defer client.Stop()
+
+	var messages []*Message
+	for len(messages) < n {
+		select {
+		case m := <-events:
+			if m.Data != "" {
+				messages = append(messages, m)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("replayed %d messages, want %d", len(messages), n)
+		}
+	}
+	return messages, time.Since(start)
+}
+
+func TestReplayHandler(t *testing.T) {
+	path := writeRecording(t, 100*time.Millisecond,
+		&Message{ID: "1", Event: "order", Data: "line1\nline2\n"},
+		&Message{Comment: "heartbeat"},
+		&Message{ID: "2", Data: "b\n"},
+	)
+
+	messages, elapsed := replay(t, ReplayHandler(path, 2), "", 2)
+	if messages[0].ID != "1" || messages[0].Event != "order" || messages[0].Data != "line1\nline2\n" || messages[1].ID != "2" {
+		t.Fatalf("replayed %+v, %+v", messages[0], messages[1])
+	}
+	// 200ms recorded at double speed
+	if elapsed < 80*time.Millisecond {
+		t.Fatalf("replay took %v, want about 100ms", elapsed)
+	}
+
+	if _, elapsed = replay(t, ReplayHandler(path, 0), "", 2); elapsed > 80*time.Millisecond {
+		t.Fatalf("replay without timing took %v", elapsed)
+	}
+
+	messages, _ = replay(t, ReplayHandler(path, 0), "1", 1)
+	if messages[0].ID != "2" {
+		t.Fatalf("replay after Last-Event-ID 1 started at %s", messages[0].ID)
+	}
+}
+
+func TestReplayHandlerMissingFile(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	ReplayHandler(filepath.Join(t.TempDir(), "missing.jsonl"), 1).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", recorder.Code)
+	}
+}
@@ -0,0 +1,227 @@
+package sse
+
+import (
+	"runtime"
+	"sync"
+)
+
+const registryShards = 32 //连接表分片数
+
+// registry lock-sharded connection table
+// links are spread over the shards by client ID, so registering, unregistering and
+// direct sends only lock one shard while broadcasts walk the shards in parallel
+type registry struct {
+	shards [registryShards]registryShard
+}
+
+// registryShard part of the connection table, zone -> client ID -> Link
+type registryShard struct {
+	mu    sync.RWMutex
+	zones map[string]map[string]Link
+}
+
+// target a link collected for fan-out
+type target struct {
+	zone string
+	id   string
+	link Link
+}
+
+// fanoutJob push message to the links of zone ("" for all zones) in one shard
+type fanoutJob struct {
+	shard     *registryShard
+	zone      string
+	message   *Message
+	key       string             //Packet.CoalesceKey
+	collector *deliveryCollector //SendMessageContext 收集结果,可为空
+	sent      *[]target          //推送成功的连接,由 fanoutCollect 在 worker 之外回调 SentFunc
+	wg        *sync.WaitGroup
+}
+
+func newRegistry() *registry {
+	r := &registry{}
+	for i := range r.shards {
+		r.shards[i].zones = make(map[string]map[string]Link)
+	}
+	return r
+}
+
+// shard FNV-1a hash of the client ID
+func (r *registry) shard(id string) *registryShard {
+	h := uint32(2166136261)
+	for i := 0; i < len(id); i++ {
+		h ^= uint32(id[i])
+		h *= 16777619
+	}
+	return &r.shards[h%registryShards]
+}
+
+// add register link in zone
+func (r *registry) add(zone, id string, link Link) {
+	s := r.shard(id)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.zones[zone] == nil {
+		s.zones[zone] = make(map[string]Link)
+	}
+	s.zones[zone][id] = link
+}
+
+// remove unregister the link of id in zone, the zone itself is kept
+func (r *registry) remove(zone, id string) {
+	s := r.shard(id)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if links, ok := s.zones[zone]; ok {
+		delete(links, id)
+	}
+}
+
+// removeLink unregister the link of id in zone if it is still link,
+// false when a newer connection with the same ID replaced it
+func (r *registry) removeLink(zone, id string, link Link) bool {
+	s := r.shard(id)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, ok := s.zones[zone][id]
+	if !ok || current.messageChan != link.messageChan {
+		return false
+	}
+	delete(s.zones[zone], id)
+	return true
+}
+
+// ensure create zone without links
+func (r *registry) ensure(zone string) {
+	s := r.shard("")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.zones[zone] == nil {
+		s.zones[zone] = make(map[string]Link)
+	}
+}
+
+// zoneNames every zone ever registered
+func (r *registry) zoneNames() []string {
+	seen := make(map[string]struct{})
+	var names []string
+	for i := range r.shards {
+		s := &r.shards[i]
+		s.mu.RLock()
+		for zone := range s.zones {
+			if _, ok := seen[zone]; !ok {
+				seen[zone] = struct{}{}
+				names = append(names, zone)
+			}
+		}
+		s.mu.RUnlock()
+	}
+	return names
+}
+
+// get the link of id in zone
+func (r *registry) get(zone, id string) (Link, bool) {
+	s := r.shard(id)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	link, ok := s.zones[zone][id]
+	return link, ok
+}
+
+// find the zone and link of id
+func (r *registry) find(id string) (string, Link, bool) {
+	s := r.shard(id)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for zone, links := range s.zones {
+		if link, ok := links[id]; ok {
+			return zone, link, true
+		}
+	}
+	return "", Link{}, false
+}
+
+// zone reports whether zone was ever registered and how many links it holds
+func (r *registry) zone(zone string) (bool, int) {
+	exists, count := false, 0
+	for i := range r.shards {
+		s := &r.shards[i]
+		s.mu.RLock()
+		if links, ok := s.zones[zone]; ok {
+			exists = true
+			count += len(links)
+		}
+		s.mu.RUnlock()
+	}
+	return exists, count
+}
+
+// targets copy the links of zone ("" for all zones) so they can be pushed to without holding the lock
+func (s *registryShard) targets(zone string) []target {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var targets []target
+	for z, links := range s.zones {
+		if zone != "" && z != zone {
+			continue
+		}
+		for id, link := range links {
+			targets = append(targets, target{zone: z, id: id, link: link})
+		}
+	}
+	return targets
+}
+
+// startFanout start the broadcast worker pool, one worker per CPU
+func (hub *Hub) startFanout() {
+	for i := 0; i < runtime.GOMAXPROCS(0); i++ {
+		go func() {
+			for job := range hub.jobs {
+				hub.fanoutShard(job)
+			}
+		}()
+	}
+}
+
+// fanout push message to every link of zone ("" for all zones), shards are handled by the worker pool in parallel
+func (hub *Hub) fanout(zone string, message *Message, key string) {
+	hub.fanoutCollect(zone, message, key, nil)
+}
+
+// fanoutCollect fanout recording delivered and blocked links into collector.
+// SentFunc is called after the workers are done, so it may broadcast itself without
+// waiting for a worker that is busy with this fanout
+func (hub *Hub) fanoutCollect(zone string, message *Message, key string, collector *deliveryCollector) {
+	var wg sync.WaitGroup
+	sent := make([][]target, registryShards)
+	wg.Add(registryShards)
+	for i := range hub.cons.shards {
+		hub.jobs <- fanoutJob{shard: &hub.cons.shards[i], zone: zone, message: message, key: key, collector: collector, sent: &sent[i], wg: &wg}
+	}
+	wg.Wait()
+	for _, targets := range sent {
+		for _, t := range targets {
+			hub.broadcastReply(t.zone, t.id, message)
+		}
+	}
+}
+
+// fanoutShard push to the links of one shard, blocked links are skipped
+func (hub *Hub) fanoutShard(job fanoutJob) {
+	defer job.wg.Done()
+	keys := make(map[string]string) //zone -> coalesce key
+	for _, t := range job.shard.targets(job.zone) {
+		key, ok := keys[t.zone]
+		if !ok {
+			key = hub.coalesceKey(t.zone, job.key, job.message)
+			keys[t.zone] = key
+		}
+		result := t.link.push(job.message, key)
+		if result != pushBlocked {
+			*job.sent = append(*job.sent, t)
+		}
+		if job.collector != nil {
+			job.collector.collect(t, result)
+		}
+	}
+}
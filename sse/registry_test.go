@@ -0,0 +1,129 @@
+package sse
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRegistry(t *testing.T) {
+	r := newRegistry()
+	link := Link{messageChan: make(chan *Message, 1)}
+
+	if exists, _ := r.zone("zone"); exists {
+		t.Fatal("zone() reports unknown zone")
+	}
+	r.add("zone", "a", link)
+	r.add("zone", "b", link)
+	r.add("other", "c", link)
+	if exists, count := r.zone("zone"); !exists || count != 2 {
+		t.Fatalf("zone() = %v %d, want true 2", exists, count)
+	}
+	if _, ok := r.get("zone", "c"); ok {
+		t.Fatal("get() found link of another zone")
+	}
+	if zone, _, ok := r.find("c"); !ok || zone != "other" {
+		t.Fatalf("find() = %q %v, want other", zone, ok)
+	}
+	if _, _, ok := r.find("missing"); ok {
+		t.Fatal("find() found missing link")
+	}
+
+	r.remove("zone", "a")
+	r.remove("zone", "b")
+	r.remove("missing", "a")
+	if exists, count := r.zone("zone"); !exists || count != 0 {
+		t.Fatalf("zone() = %v %d, want kept empty zone", exists, count)
+	}
+
+	total := 0
+	for i := range r.shards {
+		total += len(r.shards[i].targets(""))
+	}
+	if total != 1 {
+		t.Fatalf("targets() total = %d, want 1", total)
+	}
+}
+
+func TestHub_fanoutConcurrent(t *testing.T) {
+	hub := NewHub(nil)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				id := fmt.Sprintf("%d-%d", i, j)
+				hub.cons.add("zone", id, Link{messageChan: make(chan *Message, 1)})
+				hub.UnRegisterBlock("zone", id)
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				hub.broadcastMessage(Packet{Message: &Message{Data: "data"}})
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestHub_SentFuncBroadcast(t *testing.T) {
+	hub := NewHub(nil)
+	for i := 0; i < 64; i++ {
+		hub.cons.add("orders", fmt.Sprintf("client-%d", i), Link{messageChan: make(chan *Message, 1)})
+	}
+	audit := make(chan *Message, 64)
+	hub.cons.add("audit", "auditor", Link{messageChan: audit})
+	var sent int32
+	hub.SentFunc = func(zone, clientID string, message *Message) {
+		if zone != "orders" {
+			return
+		}
+		atomic.AddInt32(&sent, 1)
+		// publishing from SentFunc must not wait for the busy fanout workers
+		_ = hub.SendMessage(Packet{Zone: "audit", Broadcast: true, Message: &Message{Data: clientID}})
+		<-audit
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- hub.SendMessage(Packet{Zone: "orders", Broadcast: true, Message: &Message{Data: "x"}})
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("SendMessage() error = %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("broadcast from SentFunc deadlocked the hub")
+	}
+	if got := atomic.LoadInt32(&sent); got != 64 {
+		t.Fatalf("SentFunc calls = %d, want 64", got)
+	}
+}
+
+func BenchmarkHub_broadcastMessage(b *testing.B) {
+	for _, n := range []int{10000, 100000} {
+		b.Run(fmt.Sprintf("links=%d", n), func(b *testing.B) {
+			hub := NewHub(nil)
+			links := make([]Link, n)
+			for i := range links {
+				links[i] = Link{messageChan: make(chan *Message, 1)}
+				hub.cons.add(fmt.Sprintf("zone-%d", i%10), fmt.Sprintf("client-%d", i), links[i])
+			}
+			message := &Message{Event: "event", Data: "data"}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				hub.broadcastMessage(Packet{Message: message})
+				b.StopTimer()
+				for _, link := range links {
+					<-link.messageChan
+				}
+				b.StartTimer()
+			}
+		})
+	}
+}
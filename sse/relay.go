@@ -0,0 +1,110 @@
+package sse
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Relay subscribes to an upstream SSE stream with Client (reconnecting on failure)
+// and republishes every message as a broadcast into a zone of the Hub
+type Relay struct {
+	hub       *Hub
+	zone      string
+	client    *Client
+	rename    map[string]string           //上游事件名称 -> 转发事件名称
+	filter    func(message *Message) bool //返回 false 的消息不转发
+	publisher string                      //转发时的 Packet.Publisher
+}
+
+// RelayOption configures a Relay
+type RelayOption func(*Relay)
+
+// NewRelay relay upstream into zone, reconnectDelay is the delay before reconnecting to upstream (default 3s)
+func (hub *Hub) NewRelay(upstream, zone string, reconnectDelay time.Duration, opts ...RelayOption) *Relay {
+	r := &Relay{
+		hub:    hub,
+		zone:   zone,
+		client: NewClient(upstream, http.MethodGet, reconnectDelay),
+		rename: map[string]string{},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	r.client.messageHandler = r.relay
+	return r
+}
+
+// WithRelayRename rename upstream events (upstream name -> name in the zone), other events keep their name
+func WithRelayRename(rename map[string]string) RelayOption {
+	return func(r *Relay) {
+		for from, to := range rename {
+			r.rename[from] = to
+		}
+	}
+}
+
+// WithRelayEvents relay only the upstream events, combined with WithRelayFilter both must pass
+func WithRelayEvents(events ...string) RelayOption {
+	allowed := append([]string{}, events...)
+	return WithRelayFilter(func(message *Message) bool {
+		return containsString(allowed, message.Event)
+	})
+}
+
+// WithRelayFilter relay only messages for which keep returns true (called with the upstream event name)
+func WithRelayFilter(keep func(message *Message) bool) RelayOption {
+	return func(r *Relay) {
+		if r.filter == nil {
+			r.filter = keep
+			return
+		}
+		prev := r.filter
+		r.filter = func(message *Message) bool {
+			return prev(message) && keep(message)
+		}
+	}
+}
+
+// WithRelayPublisher Packet.Publisher of the relayed messages, see ZonePolicy
+func WithRelayPublisher(publisher string) RelayOption {
+	return func(r *Relay) {
+		r.publisher = publisher
+	}
+}
+
+// Client the upstream Client, e.g. to set OnConnection or OnDisconnect
+func (r *Relay) Client() *Client {
+	return r.client
+}
+
+// Start relay until Stop is called, it blocks like Client.Start
+func (r *Relay) Start() {
+	r.hub.cons.ensure(r.zone)
+	r.client.Start()
+}
+
+// Stop the relay
+func (r *Relay) Stop() {
+	r.client.Stop()
+}
+
+// relay republish an upstream message into the zone
+func (r *Relay) relay(upstream *Message) {
+	if len(upstream.Data) == 0 || (r.filter != nil && !r.filter(upstream)) {
+		return
+	}
+	message := &Message{
+		ID:    upstream.ID,
+		Event: upstream.Event,
+		Data:  upstream.Text(),
+	}
+	if name, ok := r.rename[upstream.Event]; ok {
+		message.Event = name
+	}
+	err := r.hub.SendMessage(Packet{Message: message, Zone: r.zone, Broadcast: true, Publisher: r.publisher})
+	if err != nil && !errors.Is(err, ErrNoConnections) && r.hub.log != nil {
+		r.hub.log.Warn(fmt.Sprintf("relay %s into %s fail:%+v", message.Event, r.zone, err))
+	}
+}
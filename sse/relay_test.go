@@ -0,0 +1,119 @@
+package sse
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHub_NewRelay(t *testing.T) {
+	var connections int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		if atomic.AddInt32(&connections, 1) == 1 {
+			// the first connection ends, the relay has to reconnect
+			fmt.Fprint(w, "id: 1\nevent: price\ndata: {\"p\":1}\n\n")
+			fmt.Fprint(w, "id: 2\nevent: debug\ndata: skip\n\n")
+			fmt.Fprint(w, "id: 3\nevent: news\ndata: hello\n\n")
+			return
+		}
+		fmt.Fprint(w, "id: 4\nevent: price\ndata: {\"p\":2}\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer upstream.Close()
+
+	hub := NewHub(nil)
+	ch := make(chan *Message, 10)
+	addLinks(hub, "feed", map[string]Link{"client": {messageChan: ch, allowPush: make(chan struct{})}})
+	hub.SetZonePolicy("feed", &ZonePolicy{Publishers: []string{"relay"}})
+
+	relay := hub.NewRelay(upstream.URL, "feed", 10*time.Millisecond,
+		WithRelayRename(map[string]string{"price": "quote"}),
+		WithRelayFilter(func(message *Message) bool { return message.Event != "debug" }),
+		WithRelayPublisher("relay"),
+	)
+	go relay.Start()
+	defer relay.Stop()
+
+	want := []Message{
+		{ID: "1", Event: "quote", Data: `{"p":1}`},
+		{ID: "3", Event: "news", Data: "hello"},
+		{ID: "4", Event: "quote", Data: `{"p":2}`},
+	}
+	for _, w := range want {
+		select {
+		case got := <-ch:
+			if got.ID != w.ID || got.Event != w.Event || got.Data != w.Data {
+				t.Fatalf("relayed %+v, want %+v", *got, w)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("message %s was not relayed", w.ID)
+		}
+	}
+}
+
+func TestHub_NewRelayMultiline(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "id: 1\nevent: news\ndata: line1\ndata: line2\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer upstream.Close()
+
+	hub := NewHub(nil)
+	downstream := httptest.NewServer(hub.Handler(WithZone("feed")))
+	defer downstream.Close()
+	client := NewClient(downstream.URL, http.MethodGet, time.Millisecond)
+	received := make(chan *Message, 1)
+	connected := make(chan struct{}, 1)
+	client.SubscribeEvent("ping", func(message *Message) {
+		connected <- struct{}{}
+	})
+	client.SubscribeEvent("news", func(message *Message) {
+		received <- message
+	})
+	go client.Start()
+	defer client.Stop()
+	select {
+	case <-connected:
+	case <-time.After(2 * time.Second):
+		t.Fatal("client did not connect")
+	}
+
+	relay := hub.NewRelay(upstream.URL, "feed", 10*time.Millisecond)
+	go relay.Start()
+	defer relay.Stop()
+	select {
+	case got := <-received:
+		if got.Text() != "line1\nline2" {
+			t.Fatalf("received %q, want both lines", got.Text())
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("message was not relayed")
+	}
+}
+
+func TestWithRelayEvents(t *testing.T) {
+	hub := NewHub(nil)
+	relay := hub.NewRelay("http://localhost", "feed", 0, WithRelayEvents("a", "b"), WithRelayFilter(func(message *Message) bool {
+		return message.Data != "skip"
+	}))
+	tests := []struct {
+		message *Message
+		want    bool
+	}{
+		{message: &Message{Event: "a", Data: "x"}, want: true},
+		{message: &Message{Event: "c", Data: "x"}, want: false},
+		{message: &Message{Event: "b", Data: "skip"}, want: false},
+	}
+	for _, tt := range tests {
+		if got := relay.filter(tt.message); got != tt.want {
+			t.Errorf("filter(%s, %s) = %v, want %v", tt.message.Event, tt.message.Data, got, tt.want)
+		}
+	}
+}
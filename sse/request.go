@@ -0,0 +1,146 @@
+package sse
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// ErrCustomTransport the transport of the http client is not an *http.Transport,
+// TLS and proxy settings must be configured on it directly
+var ErrCustomTransport = errors.New("sse: transport is not an *http.Transport")
+
+// SetHeader set a header sent with every request (connection attempts and replies)
+func (c *Client) SetHeader(key, value string) {
+	c.header.Set(key, value)
+}
+
+// SetHeaderFunc fn is called before every request and its headers override SetHeader,
+// e.g. to refresh a bearer token. An error fails the attempt, which is retried like a network error
+func (c *Client) SetHeaderFunc(fn func() (http.Header, error)) {
+	c.headerFunc = fn
+}
+
+// SetBody send body with contentType on every connection attempt, for streams opened by POST
+func (c *Client) SetBody(contentType string, body []byte) {
+	c.bodyType = contentType
+	c.body = body
+}
+
+// SetHTTPClient use client for the connection and replies, it should not have a Timeout
+// since that also limits how long a stream may stay open
+func (c *Client) SetHTTPClient(client *http.Client) {
+	c.client = client
+}
+
+// SetTransport use rt to make requests
+func (c *Client) SetTransport(rt http.RoundTripper) {
+	c.client.Transport = rt
+}
+
+// SetTLSConfig use config for https connections, see LoadClientTLS for client certificates
+func (c *Client) SetTLSConfig(config *tls.Config) error {
+	t, err := c.transport()
+	if err != nil {
+		return err
+	}
+	t.TLSClientConfig = config
+	return nil
+}
+
+// SetProxy connect through proxy, e.g. http.ProxyURL(u); nil connects directly.
+// The default is http.ProxyFromEnvironment
+func (c *Client) SetProxy(proxy func(*http.Request) (*url.URL, error)) error {
+	t, err := c.transport()
+	if err != nil {
+		return err
+	}
+	t.Proxy = proxy
+	return nil
+}
+
+// transport the *http.Transport of the client, a copy of the default transport is installed
+// the first time so the settings never leak into http.DefaultTransport
+func (c *Client) transport() (*http.Transport, error) {
+	if c.client.Transport == nil {
+		c.client.Transport = http.DefaultTransport.(*http.Transport).Clone()
+	}
+	t, ok := c.client.Transport.(*http.Transport)
+	if !ok {
+		return nil, ErrCustomTransport
+	}
+	if t == http.DefaultTransport {
+		t = t.Clone()
+		c.client.Transport = t
+	}
+	return t, nil
+}
+
+// LoadClientTLS build a TLS config presenting the client certificate in certFile/keyFile,
+// caFile (optional) is a PEM bundle trusted instead of the system roots
+func LoadClientTLS(certFile, keyFile, caFile string) (*tls.Config, error) {
+	config := &tls.Config{}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("sse: no certificates found in %s", caFile)
+		}
+		config.RootCAs = pool
+	}
+	return config, nil
+}
+
+// applyHeaders add the static and dynamic headers to req
+func (c *Client) applyHeaders(req *http.Request) error {
+	for key, values := range c.header {
+		req.Header[key] = append([]string(nil), values...)
+	}
+	if c.headerFunc == nil {
+		return nil
+	}
+	header, err := c.headerFunc()
+	if err != nil {
+		return fmt.Errorf("sse: header func: %w", err)
+	}
+	for key, values := range header {
+		req.Header[http.CanonicalHeaderKey(key)] = append([]string(nil), values...)
+	}
+	return nil
+}
+
+// streamRequest the request of a connection attempt to url, without the headers of applyHeaders
+func (c *Client) streamRequest(url string) (*http.Request, error) {
+	var body io.Reader
+	if c.body != nil {
+		body = bytes.NewReader(c.body)
+	}
+	req, err := http.NewRequest(c.method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	if c.body != nil && c.bodyType != "" {
+		req.Header.Set("Content-Type", c.bodyType)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if id := c.LastEventID(); id != "" {
+		req.Header.Set("Last-Event-ID", id)
+	}
+	return req, nil
+}
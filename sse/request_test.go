@@ -0,0 +1,145 @@
+package sse
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClient_Headers(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+		want := fmt.Sprintf("Bearer token-%d", n)
+		if got := r.Header.Get("Authorization"); got != want {
+			t.Errorf("Authorization = %q, want %q", got, want)
+		}
+		if got := r.Header.Get("X-Tenant"); got != "acme" {
+			t.Errorf("X-Tenant = %q, want acme", got)
+		}
+		if n < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	var tokens int32
+	client := NewClient(server.URL, http.MethodGet, time.Millisecond)
+	client.SetHeader("X-Tenant", "acme")
+	client.SetHeaderFunc(func() (http.Header, error) {
+		n := atomic.AddInt32(&tokens, 1)
+		if n == 2 {
+			return nil, errors.New("token endpoint unavailable")
+		}
+		return http.Header{"Authorization": {fmt.Sprintf("Bearer token-%d", atomic.LoadInt32(&requests)+1)}}, nil
+	})
+	startClient(t, client)
+	if got := atomic.LoadInt32(&requests); got != 3 {
+		t.Fatalf("requests = %d, want 3", got)
+	}
+	if got := atomic.LoadInt32(&tokens); got != 4 {
+		t.Fatalf("header func calls = %d, want 4 (one failed)", got)
+	}
+}
+
+func TestClient_SetBody(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		if r.Method != http.MethodPost || string(b) != `{"topics":["orders"]}` || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("request = %s %q %q", r.Method, b, r.Header.Get("Content-Type"))
+		}
+		if atomic.AddInt32(&requests, 1) < 2 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, http.MethodPost, time.Millisecond)
+	client.SetBody("application/json", []byte(`{"topics":["orders"]}`))
+	startClient(t, client)
+	// the body is sent again on the reconnect
+	if got := atomic.LoadInt32(&requests); got != 2 {
+		t.Fatalf("requests = %d, want 2", got)
+	}
+}
+
+func TestClient_SetTLSConfig(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	defaultConfig := http.DefaultTransport.(*http.Transport).TLSClientConfig
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	client := NewClient(server.URL, http.MethodGet, time.Millisecond)
+	if err := client.SetTLSConfig(&tls.Config{RootCAs: pool}); err != nil {
+		t.Fatalf("SetTLSConfig() error = %v", err)
+	}
+	req, _ := client.streamRequest(server.URL)
+	resp, err := client.client.Do(req)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	_ = resp.Body.Close()
+	if http.DefaultTransport.(*http.Transport).TLSClientConfig != defaultConfig {
+		t.Fatal("TLS config leaked into http.DefaultTransport")
+	}
+}
+
+func TestClient_SetProxy(t *testing.T) {
+	var proxied int32
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Host == "stream.example" {
+			atomic.AddInt32(&proxied, 1)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer proxy.Close()
+
+	proxyURL, _ := url.Parse(proxy.URL)
+	client := NewClient("http://stream.example/events", http.MethodGet, time.Millisecond)
+	if err := client.SetProxy(http.ProxyURL(proxyURL)); err != nil {
+		t.Fatalf("SetProxy() error = %v", err)
+	}
+	startClient(t, client)
+	if atomic.LoadInt32(&proxied) != 1 {
+		t.Fatal("request did not go through the proxy")
+	}
+}
+
+func TestClient_SetTransportCustom(t *testing.T) {
+	client := NewClient("http://localhost/events", http.MethodGet, time.Millisecond)
+	client.SetTransport(roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return nil, errors.New("unused")
+	}))
+	if err := client.SetTLSConfig(&tls.Config{}); !errors.Is(err, ErrCustomTransport) {
+		t.Fatalf("SetTLSConfig() error = %v, want ErrCustomTransport", err)
+	}
+	if err := client.SetProxy(nil); !errors.Is(err, ErrCustomTransport) {
+		t.Fatalf("SetProxy() error = %v, want ErrCustomTransport", err)
+	}
+}
+
+func TestLoadClientTLS(t *testing.T) {
+	if _, err := LoadClientTLS("missing.crt", "missing.key", ""); err == nil {
+		t.Fatal("LoadClientTLS() want error for missing files")
+	}
+	config, err := LoadClientTLS("", "", "")
+	if err != nil || len(config.Certificates) != 0 || config.RootCAs != nil {
+		t.Fatalf("LoadClientTLS() = %+v, %v", config, err)
+	}
+}
@@ -0,0 +1,135 @@
+package sse
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultRequestTimeout = 30 * time.Second
+	maxReplySize          = 1 << 20 //回复内容最大长度
+)
+
+var (
+	// ErrClientNotFound the client is not connected to any zone
+	ErrClientNotFound = errors.New("client not exist")
+)
+
+// ReplyHeader marks the Reply POSTs of Client.HandleRequest,
+// RegisterBlock and Handler pass them to RegisterReply so replies may use the stream url
+const ReplyHeader = "X-Sse-Reply"
+
+// Reply client answer of a Hub.Request
+type Reply struct {
+	ID       string `json:"id"`              //请求消息的关联ID
+	ClientID string `json:"client_id"`       //回复的连接ID,由 Hub 填充
+	Data     string `json:"data"`            //回复内容
+	Error    string `json:"error,omitempty"` //客户端处理失败时的错误信息
+}
+
+// ReplyError the client reported an error while handling the request
+type ReplyError struct {
+	Reply *Reply
+}
+
+func (e *ReplyError) Error() string {
+	return fmt.Sprintf("client %s reply error: %s", e.Reply.ClientID, e.Reply.Error)
+}
+
+// pendingRequest a Request waiting for its Reply
+type pendingRequest struct {
+	clientID string
+	reply    chan *Reply
+}
+
+// Request sends message to clientID with a correlation ID (Message.ID) and waits until
+// the client POSTs its Reply to RegisterReply, ctx is canceled or RequestTimeout is reached.
+// a Reply carrying an error is returned together with a *ReplyError
+func (hub *Hub) Request(ctx context.Context, clientID string, message *Message) (*Reply, error) {
+	zone, _, ok := hub.cons.find(clientID)
+	if !ok {
+		return nil, ErrClientNotFound
+	}
+	if _, ok = ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, hub.requestTimeout())
+		defer cancel()
+	}
+	id, err := correlationID()
+	if err != nil {
+		return nil, err
+	}
+	pending := &pendingRequest{clientID: clientID, reply: make(chan *Reply, 1)}
+	hub.rblock.Lock()
+	hub.replies[id] = pending
+	hub.rblock.Unlock()
+	defer func() {
+		hub.rblock.Lock()
+		delete(hub.replies, id)
+		hub.rblock.Unlock()
+	}()
+
+	request := *message
+	request.ID = id
+	if err = hub.SendMessage(Packet{Message: &request, Zone: zone, ClientID: clientID}); err != nil {
+		return nil, err
+	}
+	select {
+	case reply := <-pending.reply:
+		if reply.Error != "" {
+			return reply, &ReplyError{Reply: reply}
+		}
+		return reply, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("request %s to %s: %w", id, clientID, ctx.Err())
+	}
+}
+
+// RegisterReply handles the Reply POSTed by clients as JSON, resolving the waiting Request
+func (hub *Hub) RegisterReply(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	reply := &Reply{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxReplySize)).Decode(reply); err != nil || reply.ID == "" {
+		http.Error(w, "Invalid reply", http.StatusBadRequest)
+		return
+	}
+	hub.rblock.Lock()
+	pending, ok := hub.replies[reply.ID]
+	if ok {
+		delete(hub.replies, reply.ID)
+	}
+	hub.rblock.Unlock()
+	if !ok {
+		http.Error(w, "Request not found or expired", http.StatusNotFound)
+		return
+	}
+	reply.ClientID = pending.clientID
+	pending.reply <- reply
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (hub *Hub) requestTimeout() time.Duration {
+	if hub.RequestTimeout > 0 {
+		return hub.RequestTimeout
+	}
+	return defaultRequestTimeout
+}
+
+// correlationID random ID of a request
+func correlationID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,292 @@
+package sse
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHub_Request(t *testing.T) {
+	hub := NewHub(nil)
+	link := Link{messageChan: make(chan *Message, 1), allowPush: make(chan struct{}, 1)}
+	addLinks(hub, "zone", map[string]Link{"agent": link})
+
+	go func() {
+		message := <-link.messageChan
+		body := `{"id":"` + message.ID + `","data":"done:` + message.Data + `"}`
+		hub.RegisterReply(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/reply", strings.NewReader(body)))
+	}()
+
+	reply, err := hub.Request(context.Background(), "agent", &Message{Event: "command", Data: "restart"})
+	if err != nil {
+		t.Fatalf("Request() error = %v", err)
+	}
+	if reply.Data != "done:restart" || reply.ClientID != "agent" {
+		t.Fatalf("Request() reply = %+v", reply)
+	}
+	if len(hub.replies) != 0 {
+		t.Fatal("Request() did not remove pending request")
+	}
+}
+
+func TestHub_RequestErrors(t *testing.T) {
+	hub := NewHub(nil)
+	if _, err := hub.Request(context.Background(), "missing", &Message{Data: "data"}); !errors.Is(err, ErrClientNotFound) {
+		t.Fatalf("Request() error = %v, want ErrClientNotFound", err)
+	}
+
+	hub.RequestTimeout = 10 * time.Millisecond
+	link := Link{messageChan: make(chan *Message, 1), allowPush: make(chan struct{}, 1)}
+	addLinks(hub, "zone", map[string]Link{"agent": link})
+	if _, err := hub.Request(context.Background(), "agent", &Message{Data: "data"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Request() error = %v, want timeout", err)
+	}
+
+	<-link.messageChan
+	go func() {
+		message := <-link.messageChan
+		body := `{"id":"` + message.ID + `","error":"unknown command"}`
+		hub.RegisterReply(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/reply", strings.NewReader(body)))
+	}()
+	reply, err := hub.Request(context.Background(), "agent", &Message{Data: "data"})
+	var replyErr *ReplyError
+	if !errors.As(err, &replyErr) || reply == nil || reply.Error != "unknown command" {
+		t.Fatalf("Request() reply = %+v, error = %v, want ReplyError", reply, err)
+	}
+	if !strings.Contains(err.Error(), "unknown command") {
+		t.Fatalf("ReplyError = %q", err.Error())
+	}
+}
+
+func TestHub_RegisterReply(t *testing.T) {
+	hub := NewHub(nil)
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		want   int
+	}{
+		{name: "wrong method", method: http.MethodGet, want: http.StatusMethodNotAllowed},
+		{name: "invalid json", method: http.MethodPost, body: "{", want: http.StatusBadRequest},
+		{name: "missing id", method: http.MethodPost, body: `{"data":"x"}`, want: http.StatusBadRequest},
+		{name: "unknown id", method: http.MethodPost, body: `{"id":"missing"}`, want: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			hub.RegisterReply(recorder, httptest.NewRequest(tt.method, "/reply", strings.NewReader(tt.body)))
+			if recorder.Code != tt.want {
+				t.Fatalf("status = %d, want %d", recorder.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_HandleRequest(t *testing.T) {
+	hub := NewHub(nil)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sse", func(w http.ResponseWriter, r *http.Request) {
+		hub.RegisterBlock(w, r, "agents", func() string { return "agent-1" })
+	})
+	mux.HandleFunc("/reply", hub.RegisterReply)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := NewClient(server.URL+"/sse", http.MethodGet, time.Millisecond)
+	client.SetReplyURL(server.URL + "/reply")
+	connected := make(chan struct{}, 1)
+	client.SubscribeEvent("ping", func(message *Message) {
+		connected <- struct{}{}
+	})
+	client.HandleRequest("command", func(message *Message) (string, error) {
+		if strings.TrimSpace(message.Data) == "fail" {
+			return "", errors.New("refused")
+		}
+		return "ok " + strings.TrimSpace(message.Data), nil
+	})
+	go client.Start()
+	defer client.Stop()
+
+	select {
+	case <-connected:
+	case <-time.After(time.Second):
+		t.Fatal("client did not connect")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	reply, err := hub.Request(ctx, "agent-1", &Message{Event: "command", Data: "status"})
+	if err != nil || reply.Data != "ok status" {
+		t.Fatalf("Request() reply = %+v, error = %v", reply, err)
+	}
+	reply, err = hub.Request(ctx, "agent-1", &Message{Event: "command", Data: "fail"})
+	if err == nil || reply.Error != "refused" {
+		t.Fatalf("Request() reply = %+v, error = %v, want refused", reply, err)
+	}
+}
+
+func TestClient_HandleRequestStreamURL(t *testing.T) {
+	hub := NewHub(nil)
+	server := httptest.NewServer(hub.Handler(WithIDFunc(func(r *http.Request) string { return "agent-1" })))
+	defer server.Close()
+
+	client := NewClient(server.URL, http.MethodGet, time.Millisecond)
+	connected := make(chan struct{}, 1)
+	client.SubscribeEvent("ping", func(message *Message) {
+		connected <- struct{}{}
+	})
+	client.HandleRequest("command", func(message *Message) (string, error) {
+		return "ok", nil
+	})
+	go client.Start()
+	defer client.Stop()
+
+	select {
+	case <-connected:
+	case <-time.After(time.Second):
+		t.Fatal("client did not connect")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if reply, err := hub.Request(ctx, "agent-1", &Message{Event: "command", Data: "status"}); err != nil || reply.Data != "ok" {
+		t.Fatalf("Request() reply = %+v, error = %v", reply, err)
+	}
+}
+
+func TestClient_HandleRequestTwice(t *testing.T) {
+	replies := make(chan string, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reply := &Reply{}
+		_ = json.NewDecoder(r.Body).Decode(reply)
+		replies <- reply.Data
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, http.MethodGet, time.Millisecond)
+	client.HandleRequest("command", func(message *Message) (string, error) { return "old", nil })
+	client.HandleRequest("command", func(message *Message) (string, error) { return "new", nil })
+	message := &Message{ID: "1", Event: "command", Data: "status"}
+	for _, callback := range client.messageHandlers(message) {
+		callback(message)
+	}
+	if len(replies) != 1 || <-replies != "new" {
+		t.Fatal("want a single reply from the latest handler")
+	}
+}
+
+func TestClient_HandleRequestResume(t *testing.T) {
+	hub := NewHub(nil)
+	hub.SetHistory("default", 10)
+	handler := hub.Handler(WithIDFunc(func(r *http.Request) string { return "agent-1" }), WithRetry(time.Millisecond))
+	var (
+		mu         sync.Mutex
+		disconnect context.CancelFunc
+	)
+	lastIDs := make(chan string, 2)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sse", func(w http.ResponseWriter, r *http.Request) {
+		lastIDs <- r.Header.Get("Last-Event-ID")
+		ctx, cancel := context.WithCancel(r.Context())
+		mu.Lock()
+		disconnect = cancel
+		mu.Unlock()
+		handler.ServeHTTP(w, r.WithContext(ctx))
+	})
+	mux.HandleFunc("/reply", hub.RegisterReply)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := NewClient(server.URL+"/sse", http.MethodGet, time.Millisecond)
+	client.SetReplyURL(server.URL + "/reply")
+	received := make(chan string, 10)
+	client.SubscribeEvent("a", func(message *Message) {
+		received <- message.ID
+	})
+	client.SubscribeEvent("b", func(message *Message) {
+		received <- message.ID
+	})
+	connected := make(chan struct{}, 2)
+	client.SubscribeEvent("ping", func(message *Message) {
+		connected <- struct{}{}
+	})
+	client.HandleRequest("command", func(message *Message) (string, error) {
+		return "ok", nil
+	})
+	go client.Start()
+	defer client.Stop()
+	<-lastIDs
+	select {
+	case <-connected:
+	case <-time.After(2 * time.Second):
+		t.Fatal("client did not connect")
+	}
+
+	sendHistory(t, hub, "default", 1)
+	select {
+	case <-received:
+	case <-time.After(2 * time.Second):
+		t.Fatal("broadcast was not received")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := hub.Request(ctx, "agent-1", &Message{Event: "command", Data: "status"}); err != nil {
+		t.Fatalf("Request() error = %v", err)
+	}
+	if id := client.LastEventID(); id != "1" {
+		t.Fatalf("LastEventID() = %q, want the broadcast ID 1", id)
+	}
+
+	// 2 is only retained, the client gets it by resuming from 1
+	hub.UnRegisterBlock("default", "agent-1")
+	if err := hub.SendMessage(Packet{Zone: "default", Broadcast: true, Message: &Message{ID: "2", Event: "b", Data: "x"}}); !errors.Is(err, ErrNoConnections) {
+		t.Fatalf("SendMessage() error = %v, want ErrNoConnections", err)
+	}
+	mu.Lock()
+	disconnect()
+	mu.Unlock()
+	select {
+	case id := <-lastIDs:
+		if id != "1" {
+			t.Fatalf("Last-Event-ID = %q, want 1", id)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("client did not reconnect")
+	}
+	select {
+	case id := <-received:
+		if id != "2" {
+			t.Fatalf("replayed %s, want 2", id)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("the missed broadcast was not replayed")
+	}
+}
+
+func TestClient_postReplyStatusError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, http.MethodGet, time.Millisecond)
+	if err := client.postReply(&Reply{ID: "1"}); err == nil {
+		t.Fatal("postReply() expected status error")
+	}
+	stream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+	}))
+	defer stream.Close()
+	client.SetReplyURL(stream.URL)
+	if err := client.postReply(&Reply{ID: "1"}); err == nil {
+		t.Fatal("postReply() expected error for an event stream")
+	}
+	client.SetReplyURL("://bad-url")
+	if err := client.postReply(&Reply{ID: "1"}); err == nil {
+		t.Fatal("postReply() expected url error")
+	}
+}
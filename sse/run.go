@@ -0,0 +1,74 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+)
+
+// ErrMaxAttempts the client stopped after Backoff.MaxAttempts consecutive failed attempts,
+// the returned error also carries the error of the last attempt
+var ErrMaxAttempts = errors.New("sse: max reconnect attempts exceeded")
+
+// StatusError the server answered a connection attempt with a status other than 200
+type StatusError struct {
+	Code int //HTTP 状态码
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("sse: http status %d %s", e.Code, http.StatusText(e.Code))
+}
+
+// ContentTypeError the server answered 200 with a body that is not text/event-stream,
+// the client fails the connection as the EventSource specification requires
+type ContentTypeError struct {
+	ContentType string //响应的 Content-Type
+}
+
+func (e *ContentTypeError) Error() string {
+	return fmt.Sprintf("sse: unexpected content type %q, want text/event-stream", e.ContentType)
+}
+
+// isEventStream the Content-Type header is text/event-stream, parameters such as charset are ignored
+func isEventStream(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "text/event-stream"
+}
+
+// Run connect and block until ctx is done or the client stops.
+// It returns nil when stopped by ctx or Stop, otherwise the terminal error: a *StatusError for
+// a permanent HTTP status (204, 4xx), a *ContentTypeError when the response is not an event stream,
+// ErrMaxAttempts, or the error building the request.
+// A client runs once, it cannot be restarted after Run returns
+func (c *Client) Run(ctx context.Context) error {
+	go c.connect()
+	select {
+	case <-ctx.Done():
+		c.Stop()
+	case <-c.exitSignal:
+	}
+	if c.exitHandler != nil {
+		c.exitHandler()
+	}
+	return c.err
+}
+
+// fail stop the client with err as the result of Run, only the first call takes effect
+func (c *Client) fail(err error) {
+	var (
+		change StateChange
+		closed bool
+	)
+	c.stopOnce.Do(func() {
+		change, closed = c.closeState(err)
+		c.err = err
+		close(c.exitSignal)
+	})
+	c.flushLastEventID()
+	// outside of stopOnce, the handler may call Stop
+	if closed && c.stateHandler != nil {
+		c.stateHandler(change)
+	}
+}
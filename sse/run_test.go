@@ -0,0 +1,118 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// runClient run client with ctx and return the result of Run
+func runClient(t *testing.T, ctx context.Context, client *Client) error {
+	t.Helper()
+	done := make(chan error, 1)
+	go func() {
+		done <- client.Run(ctx)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(2 * time.Second):
+		client.Stop()
+		t.Fatal("Run() did not return")
+		return nil
+	}
+}
+
+func TestClient_RunTerminalErrors(t *testing.T) {
+	t.Run("permanent status", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}))
+		defer server.Close()
+		err := runClient(t, context.Background(), NewClient(server.URL, http.MethodGet, time.Millisecond))
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) || statusErr.Code != http.StatusUnauthorized {
+			t.Fatalf("Run() error = %v, want *StatusError 401", err)
+		}
+	})
+
+	t.Run("max attempts", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+		client := NewClient(server.URL, http.MethodGet, time.Millisecond)
+		client.SetBackoff(Backoff{MaxAttempts: 2})
+		err := runClient(t, context.Background(), client)
+		if !errors.Is(err, ErrMaxAttempts) || !strings.Contains(err.Error(), "503") {
+			t.Fatalf("Run() error = %v, want ErrMaxAttempts after 503", err)
+		}
+	})
+
+	t.Run("content type", func(t *testing.T) {
+		var requests int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = w.Write([]byte("<html>login</html>"))
+		}))
+		defer server.Close()
+		err := runClient(t, context.Background(), NewClient(server.URL, http.MethodGet, time.Millisecond))
+		var typeErr *ContentTypeError
+		if !errors.As(err, &typeErr) || typeErr.ContentType != "text/html; charset=utf-8" {
+			t.Fatalf("Run() error = %v, want *ContentTypeError", err)
+		}
+		if got := atomic.LoadInt32(&requests); got != 1 {
+			t.Fatalf("requests = %d, want 1", got)
+		}
+	})
+
+	t.Run("invalid url", func(t *testing.T) {
+		if err := runClient(t, context.Background(), NewClient("://bad", http.MethodGet, time.Millisecond)); err == nil {
+			t.Fatal("Run() error = nil, want request error")
+		}
+	})
+}
+
+func TestClient_RunCancel(t *testing.T) {
+	block := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// never answer, the client is stuck in the pending request
+		select {
+		case <-block:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(block)
+
+	exited := false
+	client := NewClient(server.URL, http.MethodGet, time.Millisecond)
+	client.OnExit(func() { exited = true })
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := runClient(t, ctx, client); err != nil {
+		t.Fatalf("Run() error = %v, want nil on cancellation", err)
+	}
+	if !exited {
+		t.Fatal("exit handler was not called")
+	}
+}
+
+func TestIsEventStream(t *testing.T) {
+	for contentType, want := range map[string]bool{
+		"text/event-stream":                true,
+		"Text/Event-Stream; charset=utf-8": true,
+		"":                                 false,
+		"application/json":                 false,
+	} {
+		if got := isEventStream(contentType); got != want {
+			t.Errorf("isEventStream(%q) = %v, want %v", contentType, got, want)
+		}
+	}
+}
@@ -5,29 +5,26 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"math/rand"
 	"net/http"
 	"strings"
-	"sync"
 	"time"
 )
 
-const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-
-var (
-	used  = make(map[string]struct{})
-	mutex sync.Mutex
-)
-
 // NewHub returns SSE total hub
 // designed to return push data for easy logging
 func NewHub(log Log) *Hub {
 	h := &Hub{
-		cons:      make(map[string]map[string]Link),
+		cons:      newRegistry(),
 		broadcast: make(chan Packet),
-		block:     sync.Mutex{},
+		jobs:      make(chan fanoutJob, registryShards),
+		polls:     make(map[string]*pollSession),
+		replies:   make(map[string]*pendingRequest),
+		coalesce:  make(map[string]func(message *Message) string),
+		policies:  make(map[string]*ZonePolicy),
+		history:   make(map[string]*historyBuffer),
 		log:       log,
 	}
+	h.startFanout()
 	//started broadcast
 	go func() {
 		h.StartBroadcast()
@@ -56,21 +53,13 @@ func (hub *Hub) deferStartBroadcast() {
 
 // broadcastMessage message to all zones connections
 func (hub *Hub) broadcastMessage(pkg Packet) {
-	for zone, cons := range hub.cons {
-		hub.broadcastZoneMessage(zone, pkg.Message, cons)
-	}
+	hub.fanout("", pkg.Message, pkg.CoalesceKey)
 }
 
 // broadcastZoneMessage zones broadcast message
 // zone is not nil, broadcast all connections
-func (hub *Hub) broadcastZoneMessage(zone string, message *Message, zones map[string]Link) {
-	for id, b := range zones {
-		select {
-		case b.messageChan <- message:
-			hub.broadcastReply(zone, id, message)
-		default:
-		}
-	}
+func (hub *Hub) broadcastZoneMessage(zone string, message *Message, key string) {
+	hub.fanout(zone, message, key)
 }
 
 // broadcastReply after broadcasting the message, push it to Chan for easy recording
@@ -78,30 +67,36 @@ func (hub *Hub) broadcastReply(zone, id string, message *Message) {
 	if hub.log != nil {
 		hub.log.Info(fmt.Sprintf("%s:%s send [%s->%s]", zone, id, message.Event, message.Data))
 	}
+	if hub.SentFunc != nil {
+		hub.SentFunc(zone, id, message)
+	}
 }
 
 // UnRegisterBlock Unregister Connection Delete Data in Map
 func (hub *Hub) UnRegisterBlock(zone, id string) {
-	hub.block.Lock()
-	defer hub.block.Unlock()
-	if links, ok := hub.cons[zone]; ok {
-		if _, exist := links[id]; exist {
-			delete(links, id)
-		}
-	}
-	return
+	hub.cons.remove(zone, id)
 }
 
 // RegisterBlock registers SSE connections
 // Zone string zone names default
-// Uuid func() string is a function that generates a connection ID, using GetClientID() by default
+// Uuid func() string is a function that generates a connection ID, using Hub.IDGenerator by default
 func (hub *Hub) RegisterBlock(w http.ResponseWriter, r *http.Request, zone string, uuid func() string) {
+	hub.registerBlock(w, r, zone, uuid, "")
+}
+
+// registerBlock RegisterBlock with the retry hint (milliseconds) of the first message, empty keeps the default
+func (hub *Hub) registerBlock(w http.ResponseWriter, r *http.Request, zone string, uuid func() string, retry string) {
+	// a client without SetReplyURL replies to the stream url
+	if r.Method == http.MethodPost && r.Header.Get(ReplyHeader) != "" {
+		hub.RegisterReply(w, r)
+		return
+	}
 	if zone == "" {
 		zone = "default"
 	}
 	if uuid == nil {
 		uuid = func() string {
-			return hub.getClientID(16)
+			return hub.getClientID()
 		}
 	}
 	id := uuid()
@@ -113,49 +108,71 @@ func (hub *Hub) RegisterBlock(w http.ResponseWriter, r *http.Request, zone strin
 	w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
-	newBlock := Link{messageChan: make(chan *Message), allowPush: make(chan struct{}), createTime: time.Now().Unix()}
-	hub.block.Lock()
-	if hub.cons[zone] == nil {
-		hub.cons[zone] = make(map[string]Link)
-	}
-	hub.cons[zone][id] = newBlock
-	hub.block.Unlock()
+	newBlock := Link{messageChan: make(chan *Message), allowPush: make(chan struct{}), pending: newCoalescer(), createTime: time.Now().Unix()}
+	hub.cons.add(zone, id, newBlock)
 	defer func() {
-		close(newBlock.messageChan)
-		hub.UnRegisterBlock(zone, id)
-		if hub.DisconnectFunc != nil {
+		// the channel is not closed, senders may still hold the Link.
+		// a newer connection with the same ID keeps its registration
+		if hub.cons.removeLink(zone, id, newBlock) && hub.DisconnectFunc != nil {
 			hub.DisconnectFunc(id)
 		}
 	}()
+	connected := hub.connectedMessage(zone, id)
+	if retry != "" {
+		connected.Retry = retry
+	}
+	// messages missed since Last-Event-ID, live messages may be interleaved or repeated
+	missed, last, resumable := hub.historyResume(zone, r.Header.Get("Last-Event-ID"))
+	if resumable {
+		// the ID of the connected message becomes the Last-Event-ID of the client,
+		// in zones with history it must be a retained message ID instead of the client ID
+		connected.ID = last
+	}
 	go func() {
-		message := Message{
-			timestamp: time.Time{},
-			ID:        id,
-			Event:     "ping",
-			Data:      fmt.Sprintf("%s->%s Connection Successful!", zone, id),
-			Retry:     "3",
+		select {
+		case newBlock.messageChan <- connected:
+		case <-r.Context().Done():
+			return
+		}
+		for _, message := range missed {
+			select {
+			case newBlock.messageChan <- message:
+			case <-r.Context().Done():
+				return
+			}
 		}
-		newBlock.messageChan <- &message
 		if hub.ConnectedFunc != nil {
 			hub.ConnectedFunc(id)
 		}
 	}()
+	// push message to client
+	push := func(message *Message) bool {
+		err := message.WriteConnect(w)
+		if err != nil {
+			if hub.log != nil {
+				hub.log.Error(fmt.Sprintf("push message to client err:%+v\n", err.Error()))
+			}
+			return false
+		}
+		flusher.Flush()
+		return true
+	}
 	for {
 		select {
 		case message := <-newBlock.messageChan:
-			// push message to client
-			err := message.WriteConnect(w)
-			if err != nil {
-				if hub.log != nil {
-					hub.log.Error(fmt.Sprintf("push message to client err:%+v\n", err.Error()))
-				}
+			if !push(message) {
 				return
 			}
-			flusher.Flush()
 			select {
 			case newBlock.allowPush <- struct{}{}:
 			default:
 			}
+		case <-newBlock.pending.ready:
+			for _, c := range newBlock.pending.take() {
+				if !push(c.message) {
+					return
+				}
+			}
 		case <-r.Context().Done():
 			// when "es.close()" is called, this loop operation will be ended.
 			return
@@ -163,6 +180,17 @@ func (hub *Hub) RegisterBlock(w http.ResponseWriter, r *http.Request, zone strin
 	}
 }
 
+// connectedMessage the first message pushed to a new connection
+func (hub *Hub) connectedMessage(zone, id string) *Message {
+	return &Message{
+		timestamp: time.Time{},
+		ID:        id,
+		Event:     "ping",
+		Data:      fmt.Sprintf("%s->%s Connection Successful!", zone, id),
+		Retry:     "3000", //毫秒
+	}
+}
+
 // WriteConnect // Push message to client
 func (m *Message) WriteConnect(w http.ResponseWriter) error {
 	msg, err := m.Format()
@@ -188,7 +216,9 @@ func (m *Message) Format() (*strings.Builder, error) {
 	defer msg.WriteString(EOF)
 	if len(m.Data) > 0 {
 		msg.WriteString(fmt.Sprintf("id: %s\n", m.ID))
-		msg.WriteString(fmt.Sprintf("data: %s\n", m.Data))
+		for _, line := range dataLines(m.Data) {
+			msg.WriteString(fmt.Sprintf("data: %s\n", line))
+		}
 		if len(m.Event) > 0 {
 			msg.WriteString(fmt.Sprintf("event: %s\n", m.Event))
 		}
@@ -202,51 +232,57 @@ func (m *Message) Format() (*strings.Builder, error) {
 	return &msg, nil
 }
 
+// ErrNoConnections the zone exists but has no connections, SendMessage and SendMessageContext return it
+var ErrNoConnections = errors.New("no connections are available")
+
 // SendMessage sends messages, whether to broadcast is controlled by the Packet parameter
+// violations of the zone policy are rejected with *PolicyError
 func (hub *Hub) SendMessage(pkg Packet) error {
+	if err := hub.checkPolicy(pkg); err != nil {
+		return err
+	}
+	pkg = hub.retain(pkg)
 	lr := len(pkg.Zone)
 	ld := len(pkg.ClientID)
 	//all broadcast
 	if pkg.Broadcast && lr == 0 && ld == 0 {
 		hub.broadcast <- pkg
 	}
-	var (
-		cons map[string]Link
-		ok   bool
-	)
 	if lr != 0 {
-		hub.block.Lock()
-		cons, ok = hub.cons[pkg.Zone]
-		hub.block.Unlock()
-		if !ok {
+		exists, count := hub.cons.zone(pkg.Zone)
+		if !exists {
 			return fmt.Errorf("zone not exist")
 		}
-		if len(cons) == 0 {
-			return fmt.Errorf("no connections are available")
+		if count == 0 {
+			return ErrNoConnections
 		}
 	}
 	//zone broadcast
 	if lr != 0 && pkg.Broadcast && ld == 0 {
-		hub.broadcastZoneMessage(pkg.Zone, pkg.Message, cons)
+		hub.broadcastZoneMessage(pkg.Zone, pkg.Message, pkg.CoalesceKey)
 	}
 	//directly send with specified Client ID
 	if len(pkg.ClientID) != 0 {
-		var b Link
-		hub.block.Lock()
-		b, ok = cons[pkg.ClientID]
-		hub.block.Unlock()
+		b, ok := hub.cons.get(pkg.Zone, pkg.ClientID)
 		if !ok {
 			return nil
 		}
+		if key := hub.coalesceKey(pkg.Zone, pkg.CoalesceKey, pkg.Message); key != "" && b.pending != nil {
+			b.pending.put(key, pkg.Message)
+			hub.broadcastReply(pkg.Zone, pkg.ClientID, pkg.Message)
+			return nil
+		}
 		select {
 		case <-b.allowPush:
 			b.messageChan <- pkg.Message
+			hub.broadcastReply(pkg.Zone, pkg.ClientID, pkg.Message)
 			return nil
 		default:
 		}
 		select {
 		case b.messageChan <- pkg.Message:
 			// 消息成功推送
+			hub.broadcastReply(pkg.Zone, pkg.ClientID, pkg.Message)
 			return nil
 		default:
 			return fmt.Errorf("client message channel is blocked")
@@ -254,24 +290,3 @@ func (hub *Hub) SendMessage(pkg Packet) error {
 	}
 	return nil
 }
-
-// getClientID randomly obtain a string of 16 characters and numbers
-func (hub *Hub) getClientID(length int) string {
-	charsetLength := len(charset)
-	defer mutex.Unlock()
-	for {
-		randomString := make([]byte, length)
-
-		// 生成随机字符串
-		for i := 0; i < length; i++ {
-			randomString[i] = charset[rand.Intn(charsetLength)]
-		}
-		guid := string(randomString)
-		mutex.Lock()
-		_, exists := used[guid]
-		if !exists {
-			used[guid] = struct{}{}
-			return guid
-		}
-	}
-}
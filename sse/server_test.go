@@ -51,6 +51,15 @@ func TestMessage_Format(t *testing.T) {
 			want:    "id: 456\ndata: data content\nevent: test\nretry: 3000\n\n",
 			wantErr: false,
 		},
+		{
+			name: "multi-line data",
+			fields: fields{
+				ID:   "7",
+				Data: "line1\nline2\r\nline3",
+			},
+			want:    "id: 7\ndata: line1\ndata: line2\ndata: line3\n\n",
+			wantErr: false,
+		},
 		{
 			name: "with comment only",
 			fields: fields{
@@ -159,13 +168,14 @@ func TestHub_getClientID(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := hub.getClientID(tt.length)
+			hub.IDGenerator = RandomID(tt.length)
+			got := hub.getClientID()
 			if len(got) != tt.length {
 				t.Errorf("getClientID() length = %v, want %v", len(got), tt.length)
 			}
 
 			// Test uniqueness
-			got2 := hub.getClientID(tt.length)
+			got2 := hub.getClientID()
 			if got == got2 {
 				t.Error("getClientID() generated duplicate ID")
 			}
@@ -179,15 +189,14 @@ func TestHub_UnRegisterBlock(t *testing.T) {
 	// Setup: create a connection
 	zone := "test-zone"
 	id := "test-id"
-	hub.cons[zone] = make(map[string]Link)
-	hub.cons[zone][id] = Link{
+	hub.cons.add(zone, id, Link{
 		messageChan: make(chan *Message),
 		allowPush:   make(chan struct{}),
 		createTime:  time.Now().Unix(),
-	}
+	})
 
 	// Verify it exists
-	if _, ok := hub.cons[zone][id]; !ok {
+	if _, ok := hub.cons.get(zone, id); !ok {
 		t.Fatal("Setup failed: connection not created")
 	}
 
@@ -195,7 +204,7 @@ func TestHub_UnRegisterBlock(t *testing.T) {
 	hub.UnRegisterBlock(zone, id)
 
 	// Verify it's removed
-	if _, ok := hub.cons[zone][id]; ok {
+	if _, ok := hub.cons.get(zone, id); ok {
 		t.Error("UnRegisterBlock() did not remove connection")
 	}
 
@@ -216,17 +225,18 @@ func TestHub_deferStartBroadcast(t *testing.T) {
 func TestHub_broadcastMessageAndReply(t *testing.T) {
 	hub := NewHub(&mockLog{})
 	message := &Message{Event: "event", Data: "data"}
-	hub.cons["zone-a"] = map[string]Link{
-		"client-a": {messageChan: make(chan *Message, 1), allowPush: make(chan struct{}, 1)},
-	}
-	hub.cons["zone-b"] = map[string]Link{
+	clientA := Link{messageChan: make(chan *Message, 1), allowPush: make(chan struct{}, 1)}
+	addLinks(hub, "zone-a", map[string]Link{
+		"client-a": clientA,
+	})
+	addLinks(hub, "zone-b", map[string]Link{
 		"client-b": {messageChan: make(chan *Message), allowPush: make(chan struct{}, 1)},
-	}
+	})
 
 	hub.broadcastMessage(Packet{Message: message})
 
 	select {
-	case got := <-hub.cons["zone-a"]["client-a"].messageChan:
+	case got := <-clientA.messageChan:
 		if got != message {
 			t.Fatalf("broadcastMessage() message = %v, want %v", got, message)
 		}
@@ -314,10 +324,7 @@ func TestHub_RegisterBlockDefaultUUID(t *testing.T) {
 
 	deadline := time.After(time.Second)
 	for {
-		hub.block.Lock()
-		ready := len(hub.cons["zone"]) == 1
-		hub.block.Unlock()
-		if ready {
+		if _, count := hub.cons.zone("zone"); count == 1 {
 			break
 		}
 		select {
@@ -369,13 +376,13 @@ func TestHub_SendMessage(t *testing.T) {
 		{
 			name: "Send to specific client",
 			setup: func(hub *Hub) {
-				hub.cons["test-zone"] = map[string]Link{
+				addLinks(hub, "test-zone", map[string]Link{
 					"test-id": {
 						messageChan: make(chan *Message, 10),
 						allowPush:   make(chan struct{}, 10),
 						createTime:  time.Now().Unix(),
 					},
-				}
+				})
 			},
 			pkg: Packet{
 				Message: &Message{
@@ -392,13 +399,13 @@ func TestHub_SendMessage(t *testing.T) {
 		{
 			name: "Broadcast to zone",
 			setup: func(hub *Hub) {
-				hub.cons["test-zone"] = map[string]Link{
+				addLinks(hub, "test-zone", map[string]Link{
 					"test-id": {
 						messageChan: make(chan *Message, 10),
 						allowPush:   make(chan struct{}, 10),
 						createTime:  time.Now().Unix(),
 					},
-				}
+				})
 			},
 			pkg: Packet{
 				Message: &Message{
@@ -415,13 +422,13 @@ func TestHub_SendMessage(t *testing.T) {
 		{
 			name: "Broadcast to all",
 			setup: func(hub *Hub) {
-				hub.cons["test-zone"] = map[string]Link{
+				addLinks(hub, "test-zone", map[string]Link{
 					"test-id": {
 						messageChan: make(chan *Message, 10),
 						allowPush:   make(chan struct{}, 10),
 						createTime:  time.Now().Unix(),
 					},
-				}
+				})
 			},
 			pkg: Packet{
 				Message:   &Message{ID: "msg-all", Event: "broadcast", Data: "all"},
@@ -446,7 +453,7 @@ func TestHub_SendMessage(t *testing.T) {
 		{
 			name: "Send to empty zone",
 			setup: func(hub *Hub) {
-				hub.cons["empty-zone"] = map[string]Link{}
+				addLinks(hub, "empty-zone", map[string]Link{})
 			},
 			pkg: Packet{
 				Message:   &Message{ID: "msg4", Event: "test", Data: "data"},
@@ -458,13 +465,13 @@ func TestHub_SendMessage(t *testing.T) {
 		{
 			name: "Send to missing client",
 			setup: func(hub *Hub) {
-				hub.cons["test-zone"] = map[string]Link{
+				addLinks(hub, "test-zone", map[string]Link{
 					"other": {
 						messageChan: make(chan *Message, 1),
 						allowPush:   make(chan struct{}, 1),
 						createTime:  time.Now().Unix(),
 					},
-				}
+				})
 			},
 			pkg: Packet{
 				Message:  &Message{ID: "msg5", Event: "test", Data: "data"},
@@ -476,13 +483,13 @@ func TestHub_SendMessage(t *testing.T) {
 		{
 			name: "Send to blocked client",
 			setup: func(hub *Hub) {
-				hub.cons["test-zone"] = map[string]Link{
+				addLinks(hub, "test-zone", map[string]Link{
 					"test-id": {
 						messageChan: make(chan *Message),
 						allowPush:   make(chan struct{}),
 						createTime:  time.Now().Unix(),
 					},
-				}
+				})
 			},
 			pkg: Packet{
 				Message:  &Message{ID: "msg-blocked", Event: "test", Data: "data"},
@@ -496,13 +503,13 @@ func TestHub_SendMessage(t *testing.T) {
 			setup: func(hub *Hub) {
 				allowPush := make(chan struct{}, 1)
 				allowPush <- struct{}{}
-				hub.cons["test-zone"] = map[string]Link{
+				addLinks(hub, "test-zone", map[string]Link{
 					"test-id": {
 						messageChan: make(chan *Message, 10),
 						allowPush:   allowPush,
 						createTime:  time.Now().Unix(),
 					},
-				}
+				})
 			},
 			pkg: Packet{
 				Message:  &Message{ID: "msg6", Event: "test", Data: "data"},
@@ -526,6 +533,15 @@ func TestHub_SendMessage(t *testing.T) {
 	}
 }
 
+func TestHub_SendMessageNoConnections(t *testing.T) {
+	hub := NewHub(nil)
+	addLinks(hub, "empty-zone", map[string]Link{})
+	err := hub.SendMessage(Packet{Message: &Message{Event: "test", Data: "data"}, Zone: "empty-zone", Broadcast: true})
+	if !errors.Is(err, ErrNoConnections) {
+		t.Fatalf("SendMessage() error = %v, want ErrNoConnections", err)
+	}
+}
+
 // Mock log for testing
 type mockLog struct{}
 
@@ -542,6 +558,14 @@ func (m *mockLog) Error(args ...interface{})                 {}
 func (m *mockLog) Errorln(args ...interface{})               {}
 func (m *mockLog) Errorf(format string, args ...interface{}) {}
 
+// addLinks register links in zone, an empty map only creates the zone
+func addLinks(hub *Hub, zone string, links map[string]Link) {
+	hub.cons.ensure(zone)
+	for id, link := range links {
+		hub.cons.add(zone, id, link)
+	}
+}
+
 type plainResponseWriter struct {
 	header http.Header
 	status int
@@ -577,3 +601,58 @@ type flushErrorResponseWriter struct {
 }
 
 func (w *flushErrorResponseWriter) Flush() {}
+
+func TestHub_RegisterBlockDuplicateID(t *testing.T) {
+	hub := NewHub(nil)
+	disconnected := make(chan string, 2)
+	hub.DisconnectFunc = func(clientID string) {
+		disconnected <- clientID
+	}
+	server := httptest.NewServer(hub.Handler(WithIDFunc(func(r *http.Request) string { return "user-1" })))
+	defer server.Close()
+
+	// open a tab of user-1, returns once its connected message arrived
+	open := func() (context.CancelFunc, *http.Response) {
+		ctx, cancel := context.WithCancel(context.Background())
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+		if _, err = NewDecoder(resp.Body).Decode(); err != nil {
+			t.Fatalf("Decode() error = %v", err)
+		}
+		return cancel, resp
+	}
+	closeFirst, first := open()
+	closeSecond, second := open()
+	defer second.Body.Close()
+
+	closeFirst()
+	_ = first.Body.Close()
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case id := <-disconnected:
+		t.Fatalf("DisconnectFunc(%s) called while the second tab is open", id)
+	default:
+	}
+	if _, ok := hub.cons.get("default", "user-1"); !ok {
+		t.Fatal("closing the first tab unregistered the second")
+	}
+	if err := hub.SendMessage(Packet{Zone: "default", Broadcast: true, Message: &Message{Event: "e", Data: "x"}}); err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+
+	closeSecond()
+	select {
+	case id := <-disconnected:
+		if id != "user-1" {
+			t.Fatalf("DisconnectFunc(%s), want user-1", id)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("DisconnectFunc was not called after the last tab closed")
+	}
+	if _, ok := hub.cons.get("default", "user-1"); ok {
+		t.Fatal("user-1 is still registered")
+	}
+}
@@ -0,0 +1,136 @@
+package sse
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// snapshotVersion 2 adds the history of zones, version 1 snapshots are still restored
+const snapshotVersion = 2
+
+// ErrSnapshotVersion the snapshot was written by an unsupported format version
+var ErrSnapshotVersion = errors.New("unsupported snapshot version")
+
+// snapshot persisted Hub state, live SSE/WebSocket connections are not included
+type snapshot struct {
+	Version int               `json:"version"`
+	Created time.Time         `json:"created"`
+	Zones   []string          `json:"zones"`
+	Polls   []pollSnapshot    `json:"polls"`
+	History []historySnapshot `json:"history"`
+}
+
+// historySnapshot retained messages of a zone, see Hub.SetHistory
+type historySnapshot struct {
+	Zone     string     `json:"zone"`
+	Size     int        `json:"size"`
+	Messages []*Message `json:"messages"`
+}
+
+// pollSnapshot long-polling session with its unacknowledged messages
+type pollSnapshot struct {
+	Zone  string          `json:"zone"`
+	ID    string          `json:"id"`
+	Seq   uint64          `json:"seq"`
+	Queue []entrySnapshot `json:"queue"`
+}
+
+// entrySnapshot queued message of a long-polling session
+type entrySnapshot struct {
+	Seq     uint64   `json:"seq"`
+	Key     string   `json:"key,omitempty"`
+	Message *Message `json:"message"`
+}
+
+// Snapshot writes the zone definitions, the queued messages of long-polling sessions and the history of zones
+// as versioned JSON, so a restarted process can Restore them, polling clients resume with their cursor
+// and SSE clients with their Last-Event-ID
+func (hub *Hub) Snapshot(w io.Writer) error {
+	snap := snapshot{Version: snapshotVersion, Created: time.Now(), Zones: hub.cons.zoneNames(), Polls: []pollSnapshot{}, History: []historySnapshot{}}
+	sort.Strings(snap.Zones)
+	hub.pblock.Lock()
+	for _, s := range hub.polls {
+		ps := pollSnapshot{Zone: s.zone, ID: s.id, Seq: s.seq, Queue: make([]entrySnapshot, 0, len(s.queue))}
+		for _, e := range s.queue {
+			ps.Queue = append(ps.Queue, entrySnapshot{Seq: e.seq, Key: e.key, Message: e.message})
+		}
+		snap.Polls = append(snap.Polls, ps)
+	}
+	hub.pblock.Unlock()
+	sort.Slice(snap.Polls, func(i, j int) bool {
+		return pollKey(snap.Polls[i].Zone, snap.Polls[i].ID) < pollKey(snap.Polls[j].Zone, snap.Polls[j].ID)
+	})
+	hub.hblock.Lock()
+	for zone, b := range hub.history {
+		snap.History = append(snap.History, historySnapshot{Zone: zone, Size: b.size, Messages: append([]*Message{}, b.messages...)})
+	}
+	hub.hblock.Unlock()
+	sort.Slice(snap.History, func(i, j int) bool { return snap.History[i].Zone < snap.History[j].Zone })
+	return json.NewEncoder(w).Encode(snap)
+}
+
+// Restore loads a Snapshot into the Hub, sessions that already exist are kept.
+// restored sessions expire after PollIdleTimeout if their client does not come back,
+// the history of a zone is restored unless it already has messages, a size set by SetHistory is kept.
+// zone settings made in code (e.g. SetCoalesce) are not part of the snapshot
+func (hub *Hub) Restore(r io.Reader) error {
+	var snap snapshot
+	if err := json.NewDecoder(r).Decode(&snap); err != nil {
+		return fmt.Errorf("decode snapshot: %w", err)
+	}
+	if snap.Version < 1 || snap.Version > snapshotVersion {
+		return fmt.Errorf("%w: %d", ErrSnapshotVersion, snap.Version)
+	}
+	for _, zone := range snap.Zones {
+		hub.cons.ensure(zone)
+	}
+	for _, ps := range snap.Polls {
+		hub.pblock.Lock()
+		if _, ok := hub.polls[pollKey(ps.Zone, ps.ID)]; ok {
+			hub.pblock.Unlock()
+			continue
+		}
+		s := hub.newPollSession(ps.Zone, ps.ID)
+		s.seq = ps.Seq
+		for _, e := range ps.Queue {
+			s.queue = append(s.queue, pollEntry{seq: e.Seq, key: e.Key, message: e.Message})
+		}
+		hub.polls[pollKey(ps.Zone, ps.ID)] = s
+		hub.pblock.Unlock()
+		hub.startPoll(s)
+	}
+	for _, hs := range snap.History {
+		hub.restoreHistory(hs)
+	}
+	if hub.log != nil {
+		hub.log.Info(fmt.Sprintf("restored %d zones, %d poll sessions, %d histories from snapshot of %s", len(snap.Zones), len(snap.Polls), len(snap.History), snap.Created))
+	}
+	return nil
+}
+
+// restoreHistory load the retained messages of a zone, a history that already has messages is kept
+func (hub *Hub) restoreHistory(hs historySnapshot) {
+	if hs.Size <= 0 {
+		return
+	}
+	hub.cons.ensure(hs.Zone)
+	hub.hblock.Lock()
+	defer hub.hblock.Unlock()
+	b, ok := hub.history[hs.Zone]
+	if !ok {
+		b = &historyBuffer{size: hs.Size}
+		hub.history[hs.Zone] = b
+	}
+	if len(b.messages) > 0 {
+		return
+	}
+	for _, message := range hs.Messages {
+		if message != nil {
+			b.add(message)
+		}
+	}
+}
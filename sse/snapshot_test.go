@@ -0,0 +1,121 @@
+package sse
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHub_SnapshotRestore(t *testing.T) {
+	old := NewHub(nil)
+	old.PollTimeout = 10 * time.Millisecond
+	first := poll(t, old, "")
+	old.cons.ensure("empty-zone")
+	_ = old.SendMessage(Packet{Message: &Message{Event: "event", Data: "queued"}, Zone: "zone", ClientID: first.ClientID})
+	deadline := time.Now().Add(time.Second)
+	for {
+		old.pblock.Lock()
+		queued := len(old.polls[pollKey("zone", first.ClientID)].queue)
+		old.pblock.Unlock()
+		if queued == 2 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	var buf bytes.Buffer
+	if err := old.Snapshot(&buf); err != nil {
+		t.Fatalf("Snapshot() error = %v", err)
+	}
+
+	restored := NewHub(&mockLog{})
+	restored.PollTimeout = 10 * time.Millisecond
+	if err := restored.Restore(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if exists, _ := restored.cons.zone("empty-zone"); !exists {
+		t.Fatal("Restore() did not restore zone")
+	}
+	resp := poll(t, restored, "id="+first.ClientID+"&cursor=1")
+	if resp.ClientID != first.ClientID || len(resp.Messages) != 1 || resp.Messages[0].Data != "queued" || resp.Cursor != 2 {
+		t.Fatalf("poll after Restore() = %+v", resp)
+	}
+
+	// restored sessions keep receiving messages
+	_ = restored.SendMessage(Packet{Message: &Message{Event: "event", Data: "new"}, Zone: "zone", ClientID: first.ClientID})
+	resp = poll(t, restored, "id="+first.ClientID+"&cursor=2")
+	if len(resp.Messages) != 1 || resp.Messages[0].Data != "new" || resp.Cursor != 3 {
+		t.Fatalf("poll after Restore() = %+v", resp)
+	}
+
+	// existing sessions are kept
+	if err := restored.Restore(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	resp = poll(t, restored, "id="+first.ClientID+"&cursor=3")
+	if resp.Cursor != 3 {
+		t.Fatalf("poll after second Restore() = %+v", resp)
+	}
+}
+
+func TestHub_SnapshotHistory(t *testing.T) {
+	old := NewHub(nil)
+	old.SetHistory("orders", 3)
+	sendHistory(t, old, "orders", 4)
+	var buf bytes.Buffer
+	if err := old.Snapshot(&buf); err != nil {
+		t.Fatalf("Snapshot() error = %v", err)
+	}
+
+	restored := NewHub(nil)
+	restored.SetHistory("audit", 5)
+	if err := restored.Restore(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	page, err := restored.History("orders", HistoryQuery{})
+	if err != nil || !reflect.DeepEqual(pageIDs(page), []string{"2", "3", "4"}) {
+		t.Fatalf("History() after Restore() = %v, %v", page, err)
+	}
+	if missed, _, _ := restored.historyResume("orders", "3"); len(missed) != 1 || missed[0].ID != "4" {
+		t.Fatalf("resume after Restore() = %v", missed)
+	}
+	// the size is restored too
+	sendHistory(t, restored, "orders", 1)
+	if page, _ = restored.History("orders", HistoryQuery{}); !reflect.DeepEqual(pageIDs(page), []string{"3", "4", "1"}) {
+		t.Fatalf("History() = %v, want 3 messages", pageIDs(page))
+	}
+	if _, err = restored.History("audit", HistoryQuery{}); err != nil {
+		t.Fatalf("History() of a zone set in code error = %v", err)
+	}
+
+	// histories that already have messages are kept
+	if err = restored.Restore(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if page, _ = restored.History("orders", HistoryQuery{}); !reflect.DeepEqual(pageIDs(page), []string{"3", "4", "1"}) {
+		t.Fatalf("History() after second Restore() = %v", pageIDs(page))
+	}
+}
+
+func TestHub_RestoreVersion1(t *testing.T) {
+	hub := NewHub(nil)
+	if err := hub.Restore(strings.NewReader(`{"version":1,"zones":["orders"],"polls":[]}`)); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if exists, _ := hub.cons.zone("orders"); !exists {
+		t.Fatal("Restore() did not restore zone")
+	}
+}
+
+func TestHub_RestoreErrors(t *testing.T) {
+	hub := NewHub(nil)
+	if err := hub.Restore(strings.NewReader("{")); err == nil {
+		t.Fatal("Restore() expected decode error")
+	}
+	if err := hub.Restore(strings.NewReader(`{"version":99}`)); !errors.Is(err, ErrSnapshotVersion) {
+		t.Fatalf("Restore() error = %v, want ErrSnapshotVersion", err)
+	}
+}
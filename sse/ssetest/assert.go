@@ -0,0 +1,69 @@
+package ssetest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/EilenC/ecommon/sse"
+)
+
+// DefaultTimeout wait time of the assertion helpers
+var DefaultTimeout = time.Second
+
+// AssertSent fail t unless the recorder has (or records within DefaultTimeout) a message of event
+// pushed to zone, an empty clientID matches any connection
+func AssertSent(t testing.TB, r *Recorder, zone, clientID, event string) Sent {
+	t.Helper()
+	match := func(s Sent) bool {
+		return s.Zone == zone && (clientID == "" || s.ClientID == clientID) && s.Message.Event == event
+	}
+	deadline := time.Now().Add(DefaultTimeout)
+	for {
+		for _, s := range r.All() {
+			if match(s) {
+				return s
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("ssetest: no message of event %q sent to %s:%s, recorded %d messages", event, zone, clientID, len(r.All()))
+			return Sent{}
+		}
+		// wait for the next record
+		_, _ = r.Wait(len(r.All())+1, time.Until(deadline))
+	}
+}
+
+// AssertNotSent fail t if the recorder has a message of event pushed to zone (empty clientID matches any connection)
+func AssertNotSent(t testing.TB, r *Recorder, zone, clientID, event string) {
+	t.Helper()
+	for _, s := range r.All() {
+		if s.Zone == zone && (clientID == "" || s.ClientID == clientID) && s.Message.Event == event {
+			t.Fatalf("ssetest: unexpected message of event %q sent to %s:%s", event, s.Zone, s.ClientID)
+		}
+	}
+}
+
+// AssertReceived fail t unless c receives a message of event with data within DefaultTimeout
+func AssertReceived(t testing.TB, c *Client, event, data string) {
+	t.Helper()
+	err := c.wait(DefaultTimeout, func(msgs []*sse.Message) bool {
+		for _, message := range msgs {
+			if message.Event == event && message.Data == data {
+				return true
+			}
+		}
+		return false
+	})
+	if err != nil {
+		t.Fatalf("ssetest: client %s did not receive %s: %q, received %d messages", c.ID, event, data, len(c.Messages()))
+	}
+}
+
+// AssertNoMessage fail t if c receives any message within d
+func AssertNoMessage(t testing.TB, c *Client, d time.Duration) {
+	t.Helper()
+	if msgs, err := c.Wait(len(c.Messages())+1, d); err == nil {
+		last := msgs[len(msgs)-1]
+		t.Fatalf("ssetest: client %s received unexpected %s: %q", c.ID, last.Event, last.Data)
+	}
+}
@@ -0,0 +1,174 @@
+package ssetest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/EilenC/ecommon/sse"
+)
+
+const connectTimeout = 5 * time.Second
+
+// streamWriter in-memory http.ResponseWriter and http.Flusher of a fake connection
+type streamWriter struct {
+	header http.Header
+	writer *io.PipeWriter
+}
+
+func (w *streamWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *streamWriter) Write(b []byte) (int, error) {
+	return w.writer.Write(b)
+}
+
+func (w *streamWriter) WriteHeader(int) {}
+
+func (w *streamWriter) Flush() {}
+
+// Client a fake in-memory SSE connection registered with sse.Hub.RegisterBlock.
+// like a real connection it is busy while writing a message, SendMessage may drop messages sent
+// to it in quick succession, SendMessageContext with a timeout waits for it
+type Client struct {
+	ID      string
+	Zone    string
+	mu      sync.Mutex
+	msgs    []*sse.Message
+	changed chan struct{} //每次收到消息后关闭并替换,用于等待
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// Connect register a fake connection with a generated ID in zone,
+// it returns after the connected message was received
+func Connect(hub *sse.Hub, zone string) (*Client, error) {
+	return ConnectID(hub, zone, sse.RandomID(16)())
+}
+
+// ConnectID register a fake connection with id in zone, see Connect
+func ConnectID(hub *sse.Hub, zone, id string) (*Client, error) {
+	return ConnectRequest(hub, zone, id, httptest.NewRequest(http.MethodGet, "/", nil))
+}
+
+// ConnectRequest register a fake connection with a custom request, e.g. one carrying the Last-Event-ID header
+func ConnectRequest(hub *sse.Hub, zone, id string, r *http.Request) (*Client, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	reader, writer := io.Pipe()
+	c := &Client{ID: id, Zone: zone, changed: make(chan struct{}), cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(c.done)
+		hub.RegisterBlock(&streamWriter{header: http.Header{}, writer: writer}, r.WithContext(ctx), zone, func() string {
+			return id
+		})
+		_ = writer.Close()
+	}()
+
+	connected := make(chan error, 1)
+	go func() {
+		decoder := sse.NewDecoder(reader)
+		first := true
+		for {
+			message, err := decoder.Decode()
+			if err != nil {
+				if first {
+					connected <- err
+				}
+				return
+			}
+			message.Data = message.Text()
+			if first {
+				// the connected message of RegisterBlock
+				first = false
+				connected <- nil
+				continue
+			}
+			c.mu.Lock()
+			c.msgs = append(c.msgs, message)
+			close(c.changed)
+			c.changed = make(chan struct{})
+			c.mu.Unlock()
+		}
+	}()
+
+	select {
+	case err := <-connected:
+		if err != nil {
+			c.Close()
+			return nil, fmt.Errorf("ssetest: connect %s: %w", id, err)
+		}
+		return c, nil
+	case <-time.After(connectTimeout):
+		c.Close()
+		return nil, fmt.Errorf("%w: connect %s", ErrTimeout, id)
+	}
+}
+
+// Close disconnect the client and wait until the Hub has unregistered it
+func (c *Client) Close() {
+	c.cancel()
+	<-c.done
+}
+
+// Messages every message received after the connected message
+func (c *Client) Messages() []*sse.Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*sse.Message{}, c.msgs...)
+}
+
+// Wait until at least n messages are received, returns all of them
+func (c *Client) Wait(n int, timeout time.Duration) ([]*sse.Message, error) {
+	var got []*sse.Message
+	err := c.wait(timeout, func(msgs []*sse.Message) bool {
+		got = msgs
+		return len(msgs) >= n
+	})
+	if err != nil {
+		return got, fmt.Errorf("%w: %d of %d messages received", err, len(got), n)
+	}
+	return got, nil
+}
+
+// WaitEvent until a message of event is received, returns the first one
+func (c *Client) WaitEvent(event string, timeout time.Duration) (*sse.Message, error) {
+	var found *sse.Message
+	err := c.wait(timeout, func(msgs []*sse.Message) bool {
+		for _, message := range msgs {
+			if message.Event == event {
+				found = message
+				return true
+			}
+		}
+		return false
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: event %s not received", err, event)
+	}
+	return found, nil
+}
+
+// wait until done reports true for the received messages
+func (c *Client) wait(timeout time.Duration, done func(msgs []*sse.Message) bool) error {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		c.mu.Lock()
+		msgs := append([]*sse.Message{}, c.msgs...)
+		changed := c.changed
+		c.mu.Unlock()
+		if done(msgs) {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-deadline.C:
+			return ErrTimeout
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package ssetest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/EilenC/ecommon/sse"
+)
+
+func TestConnect(t *testing.T) {
+	hub := sse.NewHub(nil)
+	disconnected := make(chan string, 1)
+	hub.DisconnectFunc = func(clientID string) {
+		disconnected <- clientID
+	}
+	a, err := ConnectID(hub, "orders", "a")
+	if err != nil {
+		t.Fatalf("ConnectID() error = %v", err)
+	}
+	b, err := Connect(hub, "orders")
+	if err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer b.Close()
+
+	// SendMessage drops messages while a connection is still writing the previous one, wait for it
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := hub.SendMessageContext(ctx, sse.Packet{Zone: "orders", Broadcast: true, Message: &sse.Message{Event: "created", Data: "1"}}); err != nil {
+		t.Fatalf("SendMessageContext() error = %v", err)
+	}
+	if _, err := hub.SendMessageContext(ctx, sse.Packet{Zone: "orders", ClientID: "a", Message: &sse.Message{Event: "direct", Data: "2"}}); err != nil {
+		t.Fatalf("SendMessageContext() error = %v", err)
+	}
+	msgs, err := a.Wait(2, time.Second)
+	if err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+	if msgs[0].Event != "created" || msgs[0].Data != "1" || msgs[1].Event != "direct" {
+		t.Fatalf("messages = %+v %+v", msgs[0], msgs[1])
+	}
+	if _, err := b.WaitEvent("created", time.Second); err != nil {
+		t.Fatalf("WaitEvent() error = %v", err)
+	}
+	if _, err := b.WaitEvent("direct", 50*time.Millisecond); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("WaitEvent() error = %v, want ErrTimeout", err)
+	}
+	AssertReceived(t, b, "created", "1")
+	AssertNoMessage(t, b, 50*time.Millisecond)
+
+	a.Close()
+	select {
+	case id := <-disconnected:
+		if id != "a" {
+			t.Fatalf("disconnected %s, want a", id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("hub did not unregister the client")
+	}
+}
+
+func TestConnectRequest(t *testing.T) {
+	hub := sse.NewHub(nil)
+	hub.SetHistory("default", 10)
+	for _, id := range []string{"1", "2", "3"} {
+		_ = hub.SendMessage(sse.Packet{Zone: "default", Broadcast: true, Message: &sse.Message{ID: id, Event: "e", Data: id}})
+	}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Last-Event-ID", "1")
+	c, err := ConnectRequest(hub, "default", "c", r)
+	if err != nil {
+		t.Fatalf("ConnectRequest() error = %v", err)
+	}
+	defer c.Close()
+	msgs, err := c.Wait(2, time.Second)
+	if err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+	if msgs[0].ID != "2" || msgs[1].ID != "3" {
+		t.Fatalf("replayed %s %s, want 2 3", msgs[0].ID, msgs[1].ID)
+	}
+}
@@ -0,0 +1,110 @@
+// Package ssetest provides utilities for testing code that uses sse.Hub without real HTTP connections.
+package ssetest
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/EilenC/ecommon/sse"
+)
+
+// ErrTimeout the expected messages did not arrive in time
+var ErrTimeout = errors.New("ssetest: timed out")
+
+// Sent a message pushed to a connection of the Hub
+type Sent struct {
+	Zone     string
+	ClientID string
+	Message  *sse.Message
+	Time     time.Time
+}
+
+// Recorder records every message the Hub pushes to a connection (see sse.Hub.SentFunc)
+type Recorder struct {
+	mu      sync.Mutex
+	sent    []Sent
+	changed chan struct{} //每次记录后关闭并替换,用于等待
+}
+
+// NewRecorder install a Recorder on hub, a SentFunc already set on hub is still called.
+// it must be called before messages are sent
+func NewRecorder(hub *sse.Hub) *Recorder {
+	r := &Recorder{changed: make(chan struct{})}
+	next := hub.SentFunc
+	hub.SentFunc = func(zone, clientID string, message *sse.Message) {
+		r.record(Sent{Zone: zone, ClientID: clientID, Message: message, Time: time.Now()})
+		if next != nil {
+			next(zone, clientID, message)
+		}
+	}
+	return r
+}
+
+func (r *Recorder) record(s Sent) {
+	r.mu.Lock()
+	r.sent = append(r.sent, s)
+	close(r.changed)
+	r.changed = make(chan struct{})
+	r.mu.Unlock()
+}
+
+// All every recorded message in order
+func (r *Recorder) All() []Sent {
+	return r.filter(func(Sent) bool { return true })
+}
+
+// Zone recorded messages pushed to connections of zone
+func (r *Recorder) Zone(zone string) []Sent {
+	return r.filter(func(s Sent) bool { return s.Zone == zone })
+}
+
+// Client recorded messages pushed to the connection clientID
+func (r *Recorder) Client(clientID string) []Sent {
+	return r.filter(func(s Sent) bool { return s.ClientID == clientID })
+}
+
+// Event recorded messages of event
+func (r *Recorder) Event(event string) []Sent {
+	return r.filter(func(s Sent) bool { return s.Message.Event == event })
+}
+
+// Reset forget the recorded messages
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	r.sent = nil
+	r.mu.Unlock()
+}
+
+// Wait until at least n messages are recorded, returns all of them
+func (r *Recorder) Wait(n int, timeout time.Duration) ([]Sent, error) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		r.mu.Lock()
+		sent := append([]Sent{}, r.sent...)
+		changed := r.changed
+		r.mu.Unlock()
+		if len(sent) >= n {
+			return sent, nil
+		}
+		select {
+		case <-changed:
+		case <-deadline.C:
+			return sent, fmt.Errorf("%w: %d of %d messages recorded", ErrTimeout, len(sent), n)
+		}
+	}
+}
+
+func (r *Recorder) filter(keep func(Sent) bool) []Sent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list := []Sent{}
+	for _, s := range r.sent {
+		if keep(s) {
+			list = append(list, s)
+		}
+	}
+	return list
+}
@@ -0,0 +1,61 @@
+package ssetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/EilenC/ecommon/sse"
+)
+
+func TestRecorder(t *testing.T) {
+	hub := sse.NewHub(nil)
+	chained := make(chan string, 10)
+	hub.SentFunc = func(zone, clientID string, message *sse.Message) {
+		chained <- clientID
+	}
+	rec := NewRecorder(hub)
+	for _, id := range []string{"a", "b"} {
+		c, err := ConnectID(hub, "orders", id)
+		if err != nil {
+			t.Fatalf("ConnectID() error = %v", err)
+		}
+		defer c.Close()
+	}
+	c, err := ConnectID(hub, "chat", "c")
+	if err != nil {
+		t.Fatalf("ConnectID() error = %v", err)
+	}
+	defer c.Close()
+	// connected messages are not recorded, start from a clean recording anyway
+	rec.Reset()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, _ = hub.SendMessageContext(ctx, sse.Packet{Zone: "orders", Broadcast: true, Message: &sse.Message{Event: "created", Data: "1"}})
+	_, _ = hub.SendMessageContext(ctx, sse.Packet{Zone: "chat", ClientID: "c", Message: &sse.Message{Event: "said", Data: "hi"}})
+
+	if _, err := rec.Wait(3, time.Second); err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+	if got := len(rec.Zone("orders")); got != 2 {
+		t.Fatalf("Zone(orders) = %d, want 2", got)
+	}
+	if got := rec.Client("c"); len(got) != 1 || got[0].Message.Data != "hi" {
+		t.Fatalf("Client(c) = %+v", got)
+	}
+	if got := len(rec.Event("created")); got != 2 {
+		t.Fatalf("Event(created) = %d, want 2", got)
+	}
+	if got := len(chained); got != 3 {
+		t.Fatalf("previous SentFunc called %d times, want 3", got)
+	}
+	AssertSent(t, rec, "orders", "a", "created")
+	AssertSent(t, rec, "chat", "", "said")
+	AssertNotSent(t, rec, "chat", "", "created")
+
+	if _, err := rec.Wait(4, 50*time.Millisecond); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Wait() error = %v, want ErrTimeout", err)
+	}
+}
@@ -0,0 +1,122 @@
+package sse
+
+import (
+	"errors"
+	"io"
+	"sync/atomic"
+)
+
+// ReadyState connection state of a Client, mirrors EventSource.readyState
+type ReadyState int32
+
+const (
+	Connecting ReadyState = iota //连接中或等待重连
+	Open                         //已连接,正在接收消息
+	Closed                       //已停止,不再重连
+)
+
+func (s ReadyState) String() string {
+	switch s {
+	case Connecting:
+		return "connecting"
+	case Open:
+		return "open"
+	case Closed:
+		return "closed"
+	}
+	return "unknown"
+}
+
+// StateChange reported by OnStateChange
+type StateChange struct {
+	State      ReadyState
+	Attempt    int   //当前连续尝试的次数,从 1 开始,连接成功后重新计数
+	Err        error //上一次连接或读取失败的原因,Closed 时为停止原因 (Stop 为 nil)
+	StatusCode int   //最后一次响应的 HTTP 状态码,没有响应为 0
+}
+
+// ClientStats counters of a Client since it started
+type ClientStats struct {
+	Events     uint64 //收到的消息数 (含心跳)
+	Bytes      uint64 //读取的字节数
+	Reconnects uint64 //重连次数 (首次连接之后的连接尝试)
+}
+
+// clientCounters the atomically updated ClientStats
+type clientCounters struct {
+	events     uint64
+	bytes      uint64
+	reconnects uint64
+}
+
+// ReadyState the current connection state
+func (c *Client) ReadyState() ReadyState {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	return c.state.State
+}
+
+// OnStateChange handler is called on every connection attempt, when the stream opens and when
+// the client stops. It runs on the connecting goroutine, so it should return quickly
+func (c *Client) OnStateChange(handler func(change StateChange)) {
+	c.stateHandler = handler
+}
+
+// Stats the counters of the client
+func (c *Client) Stats() ClientStats {
+	return ClientStats{
+		Events:     atomic.LoadUint64(&c.counters.events),
+		Bytes:      atomic.LoadUint64(&c.counters.bytes),
+		Reconnects: atomic.LoadUint64(&c.counters.reconnects),
+	}
+}
+
+// setState record change and report it, nothing changes once the client is closed
+func (c *Client) setState(change StateChange) {
+	c.stateMu.Lock()
+	if c.state.State == Closed {
+		c.stateMu.Unlock()
+		return
+	}
+	c.state = change
+	c.stateMu.Unlock()
+	if c.stateHandler != nil {
+		c.stateHandler(change)
+	}
+}
+
+// closeState move to Closed with the reason err, the change is reported by the caller
+func (c *Client) closeState(err error) (StateChange, bool) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	if c.state.State == Closed {
+		return c.state, false
+	}
+	c.state.State = Closed
+	c.state.Err = err
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		c.state.StatusCode = statusErr.Code
+	}
+	return c.state, true
+}
+
+// disconnectReason the reason reported to OnDisconnect for the read error err
+func disconnectReason(err error) string {
+	if err == nil || errors.Is(err, io.EOF) {
+		return "stream closed by server"
+	}
+	return err.Error()
+}
+
+// countingReader count the bytes read into clientCounters.bytes
+type countingReader struct {
+	io.ReadCloser
+	counters *clientCounters
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	atomic.AddUint64(&r.counters.bytes, uint64(n))
+	return n, err
+}
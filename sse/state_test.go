@@ -0,0 +1,113 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReadyState_String(t *testing.T) {
+	for state, want := range map[ReadyState]string{Connecting: "connecting", Open: "open", Closed: "closed", 7: "unknown"} {
+		if got := state.String(); got != want {
+			t.Errorf("String() = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestClient_OnStateChange(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&requests, 1) {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "data: a\n\n: heartbeat\n\ndata: b\n\n")
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+
+	var (
+		mu      sync.Mutex
+		changes []StateChange
+	)
+	client := NewClient(server.URL, http.MethodGet, time.Millisecond)
+	if client.ReadyState() != Connecting {
+		t.Fatalf("initial ReadyState() = %v, want connecting", client.ReadyState())
+	}
+	client.OnStateChange(func(change StateChange) {
+		mu.Lock()
+		defer mu.Unlock()
+		changes = append(changes, change)
+	})
+	err := runClient(t, context.Background(), client)
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		t.Fatalf("Run() error = %v, want *StatusError", err)
+	}
+	if client.ReadyState() != Closed {
+		t.Fatalf("ReadyState() = %v, want closed", client.ReadyState())
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []struct {
+		state  ReadyState
+		status int
+		err    bool
+	}{
+		{Connecting, 0, false},
+		{Connecting, http.StatusServiceUnavailable, true},
+		{Open, http.StatusOK, false},
+		{Connecting, http.StatusOK, true}, // the stream ended
+		{Closed, http.StatusForbidden, true},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("changes = %+v, want %d", changes, len(want))
+	}
+	for i, w := range want {
+		got := changes[i]
+		if got.State != w.state || got.StatusCode != w.status || (got.Err != nil) != w.err {
+			t.Errorf("change %d = %+v, want %v status %d err %v", i, got, w.state, w.status, w.err)
+		}
+	}
+	if changes[1].Attempt != 2 || changes[2].Attempt != 2 || changes[3].Attempt != 2 {
+		t.Errorf("attempts = %d/%d/%d, want 2/2/2", changes[1].Attempt, changes[2].Attempt, changes[3].Attempt)
+	}
+
+	stats := client.Stats()
+	if stats.Events != 3 || stats.Reconnects != 2 || stats.Bytes != uint64(len("data: a\n\n: heartbeat\n\ndata: b\n\n")) {
+		t.Fatalf("Stats() = %+v", stats)
+	}
+}
+
+func TestClient_StopState(t *testing.T) {
+	client := NewClient("http://localhost/events", http.MethodGet, time.Second)
+	var changes []StateChange
+	client.OnStateChange(func(change StateChange) {
+		changes = append(changes, change)
+		client.Stop() // a handler may stop the client
+	})
+	client.Stop()
+	client.Stop()
+	if client.ReadyState() != Closed || len(changes) != 1 || changes[0].Err != nil {
+		t.Fatalf("ReadyState() = %v, changes = %+v", client.ReadyState(), changes)
+	}
+}
+
+func TestDisconnectReason(t *testing.T) {
+	if got := disconnectReason(nil); got != "stream closed by server" {
+		t.Errorf("disconnectReason(nil) = %q", got)
+	}
+	if got := disconnectReason(ErrIdleTimeout); got != ErrIdleTimeout.Error() {
+		t.Errorf("disconnectReason(ErrIdleTimeout) = %q", got)
+	}
+}
@@ -0,0 +1,186 @@
+package sse
+
+import (
+	"path"
+	"strings"
+	"sync"
+)
+
+// DefaultEvent the event of messages without an event field
+const DefaultEvent = "message"
+
+// subscription a callback subscribed to an event name or pattern
+type subscription struct {
+	pattern  string
+	callback EventCallback
+	request  bool //HandleRequest 订阅,ID 为 Hub.Request 的关联 ID
+}
+
+// subscriptions the callbacks of a Client, safe for concurrent use
+type subscriptions struct {
+	mu       sync.RWMutex
+	exact    map[string][]*subscription //事件名 -> 订阅
+	patterns []*subscription            //通配订阅,如 order.*
+	fallback EventCallback              //没有其他订阅匹配时调用
+}
+
+func newSubscriptions() *subscriptions {
+	return &subscriptions{exact: map[string][]*subscription{}}
+}
+
+// isPattern pattern contains wildcards of path.Match and is well-formed
+func isPattern(pattern string) bool {
+	if !strings.ContainsAny(pattern, "*?[") {
+		return false
+	}
+	_, err := path.Match(pattern, "")
+	return err == nil
+}
+
+func (s *subscriptions) add(pattern string, callback EventCallback) *subscription {
+	sub := &subscription{pattern: pattern, callback: callback}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if isPattern(pattern) {
+		s.patterns = append(s.patterns, sub)
+	} else {
+		s.exact[pattern] = append(s.exact[pattern], sub)
+	}
+	return sub
+}
+
+// remove a single subscription, the slices are copied so handlers being called keep their view
+func (s *subscriptions) remove(sub *subscription) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if isPattern(sub.pattern) {
+		s.patterns = without(s.patterns, sub)
+		return
+	}
+	if subs := without(s.exact[sub.pattern], sub); len(subs) > 0 {
+		s.exact[sub.pattern] = subs
+	} else {
+		delete(s.exact, sub.pattern)
+	}
+}
+
+// removeAll every subscription of pattern
+func (s *subscriptions) removeAll(pattern string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.drop(pattern)
+}
+
+// replace the subscriptions of sub.pattern with sub
+func (s *subscriptions) replace(sub *subscription) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.drop(sub.pattern)
+	if isPattern(sub.pattern) {
+		s.patterns = append(s.patterns, sub)
+	} else {
+		s.exact[sub.pattern] = []*subscription{sub}
+	}
+}
+
+// drop every subscription of pattern, s.mu is held
+func (s *subscriptions) drop(pattern string) {
+	delete(s.exact, pattern)
+	patterns := make([]*subscription, 0, len(s.patterns))
+	for _, sub := range s.patterns {
+		if sub.pattern != pattern {
+			patterns = append(patterns, sub)
+		}
+	}
+	s.patterns = patterns
+}
+
+func without(subs []*subscription, sub *subscription) []*subscription {
+	kept := make([]*subscription, 0, len(subs))
+	for _, s := range subs {
+		if s != sub {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
+
+func (s *subscriptions) setFallback(callback EventCallback) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fallback = callback
+}
+
+// handlers the callbacks of event in subscription order, exact names before patterns,
+// the fallback when nothing else matches
+func (s *subscriptions) handlers(event string) []EventCallback {
+	if event == "" {
+		event = DefaultEvent
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var callbacks []EventCallback
+	for _, sub := range s.exact[event] {
+		callbacks = append(callbacks, sub.callback)
+	}
+	for _, sub := range s.patterns {
+		if ok, _ := path.Match(sub.pattern, event); ok {
+			callbacks = append(callbacks, sub.callback)
+		}
+	}
+	if len(callbacks) == 0 && s.fallback != nil {
+		callbacks = append(callbacks, s.fallback)
+	}
+	return callbacks
+}
+
+// request reports whether event is handled by HandleRequest
+func (s *subscriptions) request(event string) bool {
+	if event == "" {
+		event = DefaultEvent
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, sub := range s.exact[event] {
+		if sub.request {
+			return true
+		}
+	}
+	for _, sub := range s.patterns {
+		if ok, _ := path.Match(sub.pattern, event); ok && sub.request {
+			return true
+		}
+	}
+	return false
+}
+
+// messageHandlers the callbacks of message, heartbeats carrying only a comment have none
+func (c *Client) messageHandlers(message *Message) []EventCallback {
+	if message.Event == "" && message.Data == "" {
+		return nil
+	}
+	return c.eventCallbacks.handlers(message.Event)
+}
+
+// Subscribe add callback for the events matching pattern, an event name or a path.Match pattern
+// such as "order.*". Messages without an event field are DefaultEvent ("message").
+// Several callbacks may subscribe the same event, they are called in subscription order
+func (c *Client) Subscribe(pattern string, callback EventCallback) (unsubscribe func()) {
+	sub := c.eventCallbacks.add(pattern, callback)
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.eventCallbacks.remove(sub)
+		})
+	}
+}
+
+// Unsubscribe remove every callback subscribed with eventName (or the same pattern)
+func (c *Client) Unsubscribe(eventName string) {
+	c.eventCallbacks.removeAll(eventName)
+}
+
+// SubscribeDefault callback for the messages no subscription matches, nil removes it
+func (c *Client) SubscribeDefault(callback EventCallback) {
+	c.eventCallbacks.setFallback(callback)
+}
@@ -0,0 +1,130 @@
+package sse
+
+import (
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// calls record the callbacks called by name
+type calls struct {
+	mu    sync.Mutex
+	names []string
+}
+
+func (c *calls) callback(name string) EventCallback {
+	return func(*Message) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.names = append(c.names, name)
+	}
+}
+
+// run call the handlers of message and return the callbacks called
+func (c *calls) run(client *Client, message *Message) []string {
+	c.mu.Lock()
+	c.names = nil
+	c.mu.Unlock()
+	for _, callback := range client.messageHandlers(message) {
+		callback(message)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.names
+}
+
+func TestClient_Subscribe(t *testing.T) {
+	client := NewClient("http://localhost/events", http.MethodGet, time.Second)
+	got := &calls{}
+	client.SubscribeEvent("order.created", got.callback("created"))
+	unsubscribe := client.Subscribe("order.created", got.callback("created2"))
+	client.Subscribe("order.*", got.callback("order.*"))
+	client.Subscribe(DefaultEvent, got.callback("message"))
+	client.SubscribeDefault(got.callback("default"))
+
+	tests := []struct {
+		name    string
+		message *Message
+		want    []string
+	}{
+		{name: "exact then pattern", message: &Message{Event: "order.created", Data: "x"}, want: []string{"created", "created2", "order.*"}},
+		{name: "pattern", message: &Message{Event: "order.paid", Data: "x"}, want: []string{"order.*"}},
+		{name: "no event field", message: &Message{Data: "x"}, want: []string{"message"}},
+		{name: "unmatched", message: &Message{Event: "user.created", Data: "x"}, want: []string{"default"}},
+		{name: "heartbeat", message: &Message{Comment: "ping"}, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if names := got.run(client, tt.message); !reflect.DeepEqual(names, tt.want) {
+				t.Fatalf("called %v, want %v", names, tt.want)
+			}
+		})
+	}
+
+	unsubscribe()
+	unsubscribe()
+	if names := got.run(client, &Message{Event: "order.created", Data: "x"}); !reflect.DeepEqual(names, []string{"created", "order.*"}) {
+		t.Fatalf("after unsubscribe called %v", names)
+	}
+	client.Unsubscribe("order.*")
+	client.Unsubscribe("order.created")
+	if names := got.run(client, &Message{Event: "order.created", Data: "x"}); !reflect.DeepEqual(names, []string{"default"}) {
+		t.Fatalf("after Unsubscribe called %v", names)
+	}
+	client.SubscribeDefault(nil)
+	if names := got.run(client, &Message{Event: "order.created", Data: "x"}); names != nil {
+		t.Fatalf("after removing the default called %v", names)
+	}
+}
+
+func TestClient_SubscribeEventReplace(t *testing.T) {
+	client := NewClient("http://localhost/events", http.MethodGet, time.Second)
+	got := &calls{}
+	client.Subscribe("order", got.callback("subscribed"))
+	client.SubscribeEvent("order", got.callback("first"))
+	client.SubscribeEvent("order", got.callback("second"))
+	if names := got.run(client, &Message{Event: "order", Data: "x"}); !reflect.DeepEqual(names, []string{"second"}) {
+		t.Fatalf("called %v, want only the latest SubscribeEvent", names)
+	}
+	client.SubscribeEvent("order.*", got.callback("first.*"))
+	client.SubscribeEvent("order.*", got.callback("second.*"))
+	if names := got.run(client, &Message{Event: "order.paid", Data: "x"}); !reflect.DeepEqual(names, []string{"second.*"}) {
+		t.Fatalf("called %v, want only the latest pattern", names)
+	}
+}
+
+func TestClient_SubscribeConcurrent(t *testing.T) {
+	server := streamServer(200)
+	defer server.Close()
+
+	client := NewClient(server.URL, http.MethodGet, time.Second)
+	client.SetSequential(0)
+	received := make(chan struct{}, 200)
+	client.Subscribe("tick", func(*Message) { received <- struct{}{} })
+	go client.Start()
+	defer client.Stop()
+
+	// subscriptions change while the listener dispatches
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				client.Subscribe("t*", func(*Message) {})()
+				client.SubscribeEvent("other", func(*Message) {})
+				client.Unsubscribe("other")
+			}
+		}()
+	}
+	wg.Wait()
+	for i := 0; i < 200; i++ {
+		select {
+		case <-received:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("received %d of 200 messages", i)
+		}
+	}
+}
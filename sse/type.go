@@ -14,12 +14,29 @@ const (
 // Hub Global SSE Hub
 // reply is nil, no record push message, otherwise it will record
 type Hub struct {
-	cons           map[string]map[string]Link
-	broadcast      chan Packet //all broadcast
-	block          sync.Mutex  //block cons
-	log            Log
-	ConnectedFunc  func(clientID string) //连接建立时的处理逻辑
-	DisconnectFunc func(clientID string) //连接建立时的处理逻辑
+	cons            *registry
+	broadcast       chan Packet    //all broadcast
+	jobs            chan fanoutJob //broadcast worker pool
+	polls           map[string]*pollSession
+	pblock          sync.Mutex //block polls
+	replies         map[string]*pendingRequest
+	rblock          sync.Mutex //block replies
+	coalesce        map[string]func(message *Message) string
+	cblock          sync.RWMutex //block coalesce
+	policies        map[string]*ZonePolicy
+	zblock          sync.RWMutex //block policies
+	history         map[string]*historyBuffer
+	hblock          sync.Mutex //block history
+	log             Log
+	ConnectedFunc   func(clientID string)                         //连接建立时的处理逻辑
+	DisconnectFunc  func(clientID string)                         //连接断开时的处理逻辑,同一ID已被新连接替换时不调用
+	ReceiveFunc     func(clientID string, message *Message)       //收到 WebSocket 客户端消息时的处理逻辑
+	CheckOrigin     func(r *http.Request) bool                    //WebSocket 握手的 Origin 校验,默认 SameOrigin
+	SentFunc        func(zone, clientID string, message *Message) //消息推送至连接后的处理逻辑,可用于记录;广播时在全部推送完成后调用,可再次 SendMessage
+	IDGenerator     IDGenerator                                   //连接ID生成器,默认 RandomID(16),只与在线连接查重
+	PollTimeout     time.Duration                                 //长轮询单次等待时间,默认 25s
+	PollIdleTimeout time.Duration                                 //长轮询会话无请求后的过期时间,默认 60s
+	RequestTimeout  time.Duration                                 //Request 未设置 deadline 时的等待时间,默认 30s
 }
 
 // Link server 连接
@@ -28,25 +45,28 @@ type Hub struct {
 type Link struct {
 	messageChan chan *Message //推送消息通道
 	allowPush   chan struct{} //控制消息是否完成
+	pending     *coalescer    //待合并发送的消息
 	createTime  int64         //连接创建时的时间戳(秒级)
 }
 
 // Packet server 消息包
 type Packet struct {
-	Message   *Message `json:"message"` //发送内容消息体
-	Zone      string   //类似区域概念,每个连接可以在不同区域中
-	ClientID  string   `json:"client_id"` //连接ID,用于标识连接
-	Broadcast bool     //是否广播
+	Message     *Message `json:"message"` //发送内容消息体
+	Zone        string   //类似区域概念,每个连接可以在不同区域中
+	ClientID    string   `json:"client_id"` //连接ID,用于标识连接
+	Broadcast   bool     //是否广播
+	CoalesceKey string   `json:"coalesce_key,omitempty"` //合并键,连接待发送队列中相同键的消息只保留最新一条
+	Publisher   string   `json:"publisher,omitempty"`    //发布者,用于 ZonePolicy 校验
 }
 
 // Message 消息内容
 type Message struct {
 	timestamp time.Time
-	ID        string //消息ID,可选
-	Event     string //server 监听事件名称,必填
-	Data      string //发送内容
-	Retry     string //重试
-	Comment   string //注释
+	ID        string `json:"id,omitempty"`      //消息ID,可选
+	Event     string `json:"event,omitempty"`   //server 监听事件名称,必填
+	Data      string `json:"data,omitempty"`    //发送内容
+	Retry     string `json:"retry,omitempty"`   //重试
+	Comment   string `json:"comment,omitempty"` //注释
 }
 
 // Decoder sse 解码器
@@ -58,15 +78,38 @@ type Decoder struct {
 type EventCallback func(message *Message)
 
 type Client struct {
+	counters          clientCounters //首字段,保证 32 位平台上原子操作的对齐
 	url               string
 	method            string
-	eventCallbacks    map[string]EventCallback
+	eventCallbacks    *subscriptions
 	client            *http.Client
 	reconnectDelay    time.Duration
+	replyURL          string
+	failover          *failoverState              //多地址故障转移,为空只连接 url
+	header            http.Header                 //每次请求附带的固定请求头
+	headerFunc        func() (http.Header, error) //每次请求前调用,返回动态请求头
+	body              []byte                      //请求体,每次重连重新发送
+	bodyType          string                      //请求体的 Content-Type
+	events            chan *Message               //Events 返回的通道,Client 停止后关闭
+	queue             chan *Message               //SetSequential 的回调队列
+	messageHandler    func(message *Message)      //每条消息按顺序调用,用于 Relay 等
+	lastEventID       string                      //最后收到的消息ID,重连时作为 Last-Event-ID
+	lastEventIDFile   string                      //持久化 lastEventID 的文件,为空不持久化
+	idMu              sync.Mutex                  //block lastEventID
+	idPending         bool                        //lastEventID 已变化,等待写入 lastEventIDFile
+	idFileMu          sync.Mutex                  //串行写入 lastEventIDFile
+	idleTimeout       time.Duration               //读取空闲超时,超时后重连
+	backoff           Backoff                     //重连间隔策略
+	serverRetry       time.Duration               //服务端 retry 字段指定的重连间隔
+	retryMu           sync.Mutex                  //block backoff serverRetry
+	state             StateChange                 //当前状态
+	stateMu           sync.Mutex                  //block state
+	stateHandler      func(change StateChange)
 	connectionHandler func()
 	disconnectHandler func(err string)
 	exitHandler       func()
-	stopSignal        chan struct{}
+	stopSignal        chan error //连接断开,携带读取错误
 	exitSignal        chan struct{}
 	stopOnce          sync.Once
+	err               error //导致 Client 停止的错误,exitSignal 关闭前写入
 }
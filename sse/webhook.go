@@ -0,0 +1,300 @@
+package sse
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	webhookQueueSize      = 1024
+	defaultWebhookTries   = 5
+	defaultWebhookBackoff = time.Second
+	defaultWebhookMaxWait = 30 * time.Second
+)
+
+// errWebhookClosed the bridge was closed before the delivery succeeded
+var errWebhookClosed = errors.New("webhook bridge closed")
+
+// WebhookEndpoint a URL every bridged message is POSTed to
+type WebhookEndpoint struct {
+	URL         string
+	Secret      string   //HMAC-SHA256 签名密钥,为空不签名
+	Concurrency int      //同时进行的请求数,默认 1 (按顺序投递)
+	Events      []string //投递的事件,为空投递所有事件
+}
+
+// WebhookBridge delivers messages of a Hub zone (SubscribeHub) or an SSE stream (SubscribeClient)
+// as HTTP POSTs to webhook endpoints. the body is the JSON of the Message, headers:
+//
+//	X-Webhook-ID         Message.ID
+//	X-Webhook-Event      Message.Event
+//	X-Webhook-Attempt    attempt number, starting at 1
+//	X-Webhook-Timestamp  unix seconds
+//	X-Webhook-Signature  sha256=<hex HMAC of "timestamp.body">, see SignWebhook
+//
+// 2xx responses are delivered, network errors, 408, 429 and 5xx are retried with exponential backoff,
+// other responses and exhausted retries are appended to DeadLetter
+type WebhookBridge struct {
+	Client      *http.Client  //默认 10s 超时
+	MaxAttempts int           //最大尝试次数,默认 5
+	Backoff     time.Duration //首次重试间隔,之后翻倍,默认 1s
+	MaxBackoff  time.Duration //重试间隔上限,默认 30s
+	DeadLetter  string        //投递失败的消息以 JSON 行追加至该文件,为空时丢弃
+	Log         Log
+	endpoints   []*webhookQueue
+	mu          sync.RWMutex //block closed
+	closed      bool
+	closing     chan struct{}
+	wg          sync.WaitGroup
+	dlock       sync.Mutex //block DeadLetter writes
+}
+
+// webhookQueue pending deliveries of an endpoint
+type webhookQueue struct {
+	endpoint WebhookEndpoint
+	queue    chan *Message
+}
+
+// WebhookDeadLetter a line of the dead-letter file
+type WebhookDeadLetter struct {
+	URL      string    `json:"url"`
+	Message  *Message  `json:"message"`
+	Attempts int       `json:"attempts"`
+	Error    string    `json:"error"`
+	Time     time.Time `json:"time"`
+}
+
+// NewWebhookBridge start the delivery workers of endpoints, fields must be set before messages are delivered
+func NewWebhookBridge(endpoints ...WebhookEndpoint) *WebhookBridge {
+	b := &WebhookBridge{
+		Client:  &http.Client{Timeout: 10 * time.Second},
+		closing: make(chan struct{}),
+	}
+	for _, endpoint := range endpoints {
+		q := &webhookQueue{endpoint: endpoint, queue: make(chan *Message, webhookQueueSize)}
+		b.endpoints = append(b.endpoints, q)
+		workers := endpoint.Concurrency
+		if workers <= 0 {
+			workers = 1
+		}
+		b.wg.Add(workers)
+		for i := 0; i < workers; i++ {
+			go b.work(q)
+		}
+	}
+	return b
+}
+
+// SignWebhook the X-Webhook-Signature of body sent at timestamp (X-Webhook-Timestamp),
+// receivers compare it with hmac.Equal
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Deliver queue message for every endpoint accepting its event,
+// when the queue of an endpoint is full the message goes to the dead letter
+func (b *WebhookBridge) Deliver(message *Message) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return
+	}
+	for _, q := range b.endpoints {
+		if len(q.endpoint.Events) > 0 && !containsString(q.endpoint.Events, message.Event) {
+			continue
+		}
+		select {
+		case q.queue <- message:
+		default:
+			b.deadLetter(q.endpoint.URL, message, 0, errors.New("webhook queue is full"))
+		}
+	}
+}
+
+// SubscribeHub deliver every message sent to zone of hub, like a connection of the zone.
+// cancel stops the subscription
+func (b *WebhookBridge) SubscribeHub(hub *Hub, zone string) (cancel func()) {
+	id := "webhook-" + RandomID(defaultIDSize)()
+	link := Link{messageChan: make(chan *Message, webhookQueueSize), allowPush: make(chan struct{}), pending: newCoalescer(), createTime: time.Now().Unix()}
+	done := make(chan struct{})
+	hub.cons.add(zone, id, link)
+	go func() {
+		for {
+			select {
+			case message := <-link.messageChan:
+				b.Deliver(message)
+			case <-link.pending.ready:
+				for _, c := range link.pending.take() {
+					b.Deliver(c.message)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			hub.UnRegisterBlock(zone, id)
+			close(done)
+		})
+	}
+}
+
+// SubscribeClient deliver every message received by c, c is started by the caller.
+// Handlers installed earlier (Relay, RecordClient) keep receiving the messages
+func (b *WebhookBridge) SubscribeClient(c *Client) {
+	next := c.messageHandler
+	c.messageHandler = func(message *Message) {
+		if next != nil {
+			next(message)
+		}
+		if len(message.Data) == 0 {
+			return
+		}
+		m := *message
+		m.Data = m.Text()
+		b.Deliver(&m)
+	}
+}
+
+// Close stop accepting messages and wait for the workers, queued messages are tried once more
+// and written to the dead letter when that fails
+func (b *WebhookBridge) Close() {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+	b.closed = true
+	close(b.closing)
+	for _, q := range b.endpoints {
+		close(q.queue)
+	}
+	b.mu.Unlock()
+	b.wg.Wait()
+}
+
+// work deliver the messages of q
+func (b *WebhookBridge) work(q *webhookQueue) {
+	defer b.wg.Done()
+	for message := range q.queue {
+		b.deliver(q.endpoint, message)
+	}
+}
+
+// deliver POST message to endpoint, retrying with backoff
+func (b *WebhookBridge) deliver(endpoint WebhookEndpoint, message *Message) {
+	body, err := json.Marshal(message)
+	if err != nil {
+		b.deadLetter(endpoint.URL, message, 0, err)
+		return
+	}
+	attempts := b.MaxAttempts
+	if attempts <= 0 {
+		attempts = defaultWebhookTries
+	}
+	wait := b.Backoff
+	if wait <= 0 {
+		wait = defaultWebhookBackoff
+	}
+	maxWait := b.MaxBackoff
+	if maxWait <= 0 {
+		maxWait = defaultWebhookMaxWait
+	}
+	for attempt := 1; ; attempt++ {
+		retry, err := b.post(endpoint, message, body, attempt)
+		if err == nil {
+			return
+		}
+		if !retry || attempt >= attempts {
+			b.deadLetter(endpoint.URL, message, attempt, err)
+			return
+		}
+		if b.Log != nil {
+			b.Log.Warn(fmt.Sprintf("webhook %s attempt %d fail:%+v, retrying in %v", endpoint.URL, attempt, err, wait))
+		}
+		select {
+		case <-time.After(wait):
+		case <-b.closing:
+			b.deadLetter(endpoint.URL, message, attempt, fmt.Errorf("%w: %v", errWebhookClosed, err))
+			return
+		}
+		if wait *= 2; wait > maxWait {
+			wait = maxWait
+		}
+	}
+}
+
+// post send one attempt, reports whether a failure may be retried
+func (b *WebhookBridge) post(endpoint WebhookEndpoint, message *Message, body []byte, attempt int) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-ID", message.ID)
+	req.Header.Set("X-Webhook-Event", message.Event)
+	req.Header.Set("X-Webhook-Attempt", strconv.Itoa(attempt))
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	if endpoint.Secret != "" {
+		req.Header.Set("X-Webhook-Signature", SignWebhook(endpoint.Secret, timestamp, body))
+	}
+	resp, err := b.Client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	_ = resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode >= 500:
+		return true, fmt.Errorf("http status code error %d", resp.StatusCode)
+	default:
+		return false, fmt.Errorf("http status code error %d", resp.StatusCode)
+	}
+}
+
+// deadLetter append the failed delivery to the dead-letter file
+func (b *WebhookBridge) deadLetter(url string, message *Message, attempts int, cause error) {
+	if b.Log != nil {
+		b.Log.Error(fmt.Sprintf("webhook %s message %s dead-lettered after %d attempts:%+v", url, message.ID, attempts, cause))
+	}
+	if b.DeadLetter == "" {
+		return
+	}
+	line, err := json.Marshal(WebhookDeadLetter{URL: url, Message: message, Attempts: attempts, Error: cause.Error(), Time: time.Now()})
+	if err != nil {
+		return
+	}
+	b.dlock.Lock()
+	defer b.dlock.Unlock()
+	f, err := os.OpenFile(b.DeadLetter, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		if b.Log != nil {
+			b.Log.Error(fmt.Sprintf("open dead letter %s fail:%+v", b.DeadLetter, err))
+		}
+		return
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	_, _ = f.Write(append(line, '\n'))
+}
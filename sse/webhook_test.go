@@ -0,0 +1,206 @@
+package sse
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWebhookBridge_Deliver(t *testing.T) {
+	var (
+		attempts int32
+		received = make(chan *Message, 10)
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if got, want := r.Header.Get("X-Webhook-Signature"), SignWebhook("secret", r.Header.Get("X-Webhook-Timestamp"), body); !hmac.Equal([]byte(got), []byte(want)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		// the first attempt fails and is retried
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		message := &Message{}
+		_ = json.Unmarshal(body, message)
+		if r.Header.Get("X-Webhook-Event") != message.Event || r.Header.Get("X-Webhook-Attempt") != "2" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received <- message
+	}))
+	defer server.Close()
+
+	b := NewWebhookBridge(WebhookEndpoint{URL: server.URL, Secret: "secret", Events: []string{"order"}})
+	b.Backoff = 10 * time.Millisecond
+	b.Deliver(&Message{ID: "1", Event: "ignored", Data: "x"})
+	b.Deliver(&Message{ID: "2", Event: "order", Data: `{"id":2}`})
+
+	select {
+	case got := <-received:
+		if got.ID != "2" || got.Data != `{"id":2}` {
+			t.Fatalf("received %+v", *got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+	b.Close()
+	if got := atomic.LoadInt32(&attempts); got != 2 {
+		t.Fatalf("attempts = %d, want 2", got)
+	}
+}
+
+func TestWebhookBridge_DeadLetter(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		if r.Header.Get("X-Webhook-Event") == "bad" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	deadLetter := filepath.Join(t.TempDir(), "dead.jsonl")
+	b := NewWebhookBridge(WebhookEndpoint{URL: server.URL})
+	b.MaxAttempts = 3
+	b.Backoff = time.Millisecond
+	b.DeadLetter = deadLetter
+	b.Deliver(&Message{ID: "1", Event: "bad", Data: "x"})
+	b.Deliver(&Message{ID: "2", Event: "retry", Data: "y"})
+	// Close aborts pending retries, wait for them first
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(&attempts) < 4 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	b.Close()
+
+	f, err := os.Open(deadLetter)
+	if err != nil {
+		t.Fatalf("open dead letter error = %v", err)
+	}
+	defer f.Close()
+	var letters []WebhookDeadLetter
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		letter := WebhookDeadLetter{}
+		if err := json.Unmarshal(scanner.Bytes(), &letter); err != nil {
+			t.Fatalf("decode dead letter error = %v", err)
+		}
+		letters = append(letters, letter)
+	}
+	if len(letters) != 2 {
+		t.Fatalf("dead letters = %d, want 2", len(letters))
+	}
+	// 400 is not retried, 500 is retried until MaxAttempts
+	if letters[0].Message.ID != "1" || letters[0].Attempts != 1 || letters[1].Message.ID != "2" || letters[1].Attempts != 3 {
+		t.Fatalf("dead letters = %+v", letters)
+	}
+	if got := atomic.LoadInt32(&attempts); got != 4 {
+		t.Fatalf("attempts = %d, want 4", got)
+	}
+}
+
+func TestWebhookBridge_Concurrency(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		inFlight int
+		peak     int
+		wg       sync.WaitGroup
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer wg.Done()
+		mu.Lock()
+		inFlight++
+		if inFlight > peak {
+			peak = inFlight
+		}
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	b := NewWebhookBridge(WebhookEndpoint{URL: server.URL, Concurrency: 2})
+	wg.Add(6)
+	for i := 0; i < 6; i++ {
+		b.Deliver(&Message{ID: fmt.Sprint(i), Event: "e", Data: "x"})
+	}
+	wg.Wait()
+	b.Close()
+	if peak != 2 {
+		t.Fatalf("peak concurrency = %d, want 2", peak)
+	}
+}
+
+func TestWebhookBridge_SubscribeHub(t *testing.T) {
+	received := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get("X-Webhook-ID")
+	}))
+	defer server.Close()
+
+	hub := NewHub(nil)
+	b := NewWebhookBridge(WebhookEndpoint{URL: server.URL})
+	defer b.Close()
+	cancel := b.SubscribeHub(hub, "orders")
+	if err := hub.SendMessage(Packet{Zone: "orders", Broadcast: true, Message: &Message{ID: "1", Event: "e", Data: "x"}}); err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+	select {
+	case id := <-received:
+		if id != "1" {
+			t.Fatalf("received %s, want 1", id)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+	cancel()
+	if err := hub.SendMessage(Packet{Zone: "orders", Broadcast: true, Message: &Message{ID: "2", Event: "e", Data: "x"}}); !errors.Is(err, ErrNoConnections) {
+		t.Fatalf("SendMessage() after cancel error = %v, want ErrNoConnections", err)
+	}
+}
+
+func TestWebhookBridge_SubscribeClient(t *testing.T) {
+	received := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get("X-Webhook-ID")
+	}))
+	defer server.Close()
+
+	b := NewWebhookBridge(WebhookEndpoint{URL: server.URL})
+	defer b.Close()
+	client := NewClient("http://localhost/events", http.MethodGet, time.Second)
+	var previous int32
+	client.messageHandler = func(message *Message) { atomic.AddInt32(&previous, 1) }
+	b.SubscribeClient(client)
+	client.messageHandler(&Message{ID: "1", Data: "x\n"})
+	client.messageHandler(&Message{Comment: "heartbeat"})
+
+	select {
+	case id := <-received:
+		if id != "1" {
+			t.Fatalf("received %s, want 1", id)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+	if got := atomic.LoadInt32(&previous); got != 2 {
+		t.Fatalf("previous handler calls = %d, want 2", got)
+	}
+}
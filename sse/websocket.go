@@ -0,0 +1,388 @@
+package sse
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// websocket opcodes, RFC 6455 section 5.2
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xA
+)
+
+const (
+	wsGUID           = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	wsMaxMessageSize = 1 << 20 //单条消息最大长度
+	wsWriteTimeout   = 10 * time.Second
+)
+
+var (
+	errWebSocketProtocol = errors.New("websocket protocol error")
+	errWebSocketTooLarge = errors.New("websocket message too large")
+)
+
+// wsConn minimal RFC 6455 server side connection
+type wsConn struct {
+	conn net.Conn
+	br   *bufio.Reader
+	wmu  sync.Mutex //writeFrame 可能同时被推送与 pong 调用
+}
+
+// RegisterWebSocket registers WebSocket connections into the same zones as RegisterBlock
+// every Message is pushed as a JSON text frame, JSON messages sent by the client are passed to ReceiveFunc
+// Zone string zone names default
+// Uuid func() string is a function that generates a connection ID, using Hub.IDGenerator by default
+func (hub *Hub) RegisterWebSocket(w http.ResponseWriter, r *http.Request, zone string, uuid func() string) {
+	if zone == "" {
+		zone = "default"
+	}
+	if uuid == nil {
+		uuid = func() string {
+			return hub.getClientID()
+		}
+	}
+	checkOrigin := hub.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = SameOrigin
+	}
+	ws, err := upgradeWebSocket(w, r, checkOrigin)
+	if err != nil {
+		if hub.log != nil {
+			hub.log.Error(fmt.Sprintf("websocket upgrade err:%+v\n", err.Error()))
+		}
+		return
+	}
+	defer func() {
+		_ = ws.conn.Close()
+	}()
+	id := uuid()
+	newBlock := Link{messageChan: make(chan *Message), allowPush: make(chan struct{}), pending: newCoalescer(), createTime: time.Now().Unix()}
+	if err = ws.writeMessage(hub.connectedMessage(zone, id)); err != nil {
+		return
+	}
+	hub.cons.add(zone, id, newBlock)
+	defer func() {
+		if hub.cons.removeLink(zone, id, newBlock) && hub.DisconnectFunc != nil {
+			hub.DisconnectFunc(id)
+		}
+	}()
+	if hub.ConnectedFunc != nil {
+		go hub.ConnectedFunc(id)
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		hub.readWebSocket(ws, zone, id)
+	}()
+	push := func(message *Message) bool {
+		if err := ws.writeMessage(message); err != nil {
+			if hub.log != nil {
+				hub.log.Error(fmt.Sprintf("push message to client err:%+v\n", err.Error()))
+			}
+			return false
+		}
+		return true
+	}
+	for {
+		select {
+		case message := <-newBlock.messageChan:
+			if !push(message) {
+				return
+			}
+			select {
+			case newBlock.allowPush <- struct{}{}:
+			default:
+			}
+		case <-newBlock.pending.ready:
+			for _, c := range newBlock.pending.take() {
+				if !push(c.message) {
+					return
+				}
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+// readWebSocket read client messages until the connection is closed
+func (hub *Hub) readWebSocket(ws *wsConn, zone, id string) {
+	for {
+		opcode, payload, err := ws.readMessage()
+		if err != nil {
+			if !errors.Is(err, io.EOF) && hub.log != nil {
+				hub.log.Warn(fmt.Sprintf("%s:%s websocket read err:%+v", zone, id, err))
+			}
+			return
+		}
+		if opcode != wsText || hub.ReceiveFunc == nil {
+			continue
+		}
+		message := &Message{}
+		if err = json.Unmarshal(payload, message); err != nil {
+			if hub.log != nil {
+				hub.log.Warn(fmt.Sprintf("%s:%s websocket message is not json:%+v", zone, id, err))
+			}
+			continue
+		}
+		hub.ReceiveFunc(id, message)
+	}
+}
+
+// SameOrigin the default Hub.CheckOrigin, requests without Origin (non-browser clients)
+// and requests whose Origin host equals the Host header are allowed
+func SameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// AllowOrigins a Hub.CheckOrigin allowing the same origin and origins (e.g. "https://app.example.com"),
+// "*" allows every origin
+func AllowOrigins(origins ...string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		if SameOrigin(r) {
+			return true
+		}
+		origin := r.Header.Get("Origin")
+		for _, o := range origins {
+			if o == "*" || strings.EqualFold(o, origin) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// upgradeWebSocket validate the opening handshake and hijack the connection,
+// browsers do not apply CORS to WebSocket so checkOrigin guards against cross-site hijacking
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request, checkOrigin func(r *http.Request) bool) (*wsConn, error) {
+	if r.Method != http.MethodGet ||
+		!headerContainsToken(r.Header, "Connection", "upgrade") ||
+		!headerContainsToken(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "WebSocket upgrade required", http.StatusBadRequest)
+		return nil, errors.New("not a websocket handshake")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "Unsupported WebSocket version", http.StatusUpgradeRequired)
+		return nil, errors.New("unsupported websocket version")
+	}
+	if !checkOrigin(r) {
+		http.Error(w, "Origin not allowed", http.StatusForbidden)
+		return nil, fmt.Errorf("websocket origin %q not allowed", r.Header.Get("Origin"))
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "Missing Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, errors.New("missing websocket key")
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "WebSocket unsupported!", http.StatusInternalServerError)
+		return nil, errors.New("response writer is not a hijacker")
+	}
+	conn, brw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	_, err = brw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + webSocketAccept(key) + "\r\n\r\n")
+	if err == nil {
+		err = brw.Flush()
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return &wsConn{conn: conn, br: brw.Reader}, nil
+}
+
+// webSocketAccept compute Sec-WebSocket-Accept from the client key
+func webSocketAccept(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// headerContainsToken reports whether the comma separated header contains token (case-insensitive)
+func headerContainsToken(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, v := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writeMessage push message as a JSON text frame
+func (c *wsConn) writeMessage(message *Message) error {
+	b, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	return c.writeFrame(wsText, b)
+}
+
+// writeFrame write a single unmasked frame
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	header := make([]byte, 2, 10)
+	header[0] = 0x80 | opcode
+	switch n := len(payload); {
+	case n < 126:
+		header[1] = byte(n)
+	case n <= 0xFFFF:
+		header[1] = 126
+		header = append(header, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(n))
+	default:
+		header[1] = 127
+		header = append(header, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(n))
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_ = c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	if _, err := c.conn.Write(append(header, payload...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// readMessage read a complete data message, answering control frames in between
+// returns io.EOF after the close handshake
+func (c *wsConn) readMessage() (byte, []byte, error) {
+	opcode, message, err := c.nextMessage()
+	if errors.Is(err, errWebSocketProtocol) {
+		_ = c.writeClose(1002)
+	} else if errors.Is(err, errWebSocketTooLarge) {
+		_ = c.writeClose(1009)
+	}
+	return opcode, message, err
+}
+
+// nextMessage join fragmented frames into one message
+func (c *wsConn) nextMessage() (byte, []byte, error) {
+	var (
+		opcode  byte
+		message []byte
+	)
+	for {
+		fin, op, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+		switch op {
+		case wsClose:
+			_ = c.writeClose(1000)
+			return 0, nil, io.EOF
+		case wsPing:
+			if err = c.writeFrame(wsPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case wsPong:
+			continue
+		case wsContinuation:
+			if opcode == 0 {
+				return 0, nil, errWebSocketProtocol
+			}
+		case wsText, wsBinary:
+			if opcode != 0 {
+				return 0, nil, errWebSocketProtocol
+			}
+			opcode = op
+		default:
+			return 0, nil, errWebSocketProtocol
+		}
+		if len(message)+len(payload) > wsMaxMessageSize {
+			return 0, nil, errWebSocketTooLarge
+		}
+		message = append(message, payload...)
+		if fin {
+			return opcode, message, nil
+		}
+	}
+}
+
+// readFrame read a single frame and unmask its payload
+func (c *wsConn) readFrame() (bool, byte, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.br, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin := header[0]&0x80 != 0
+	opcode := header[0] & 0x0F
+	if header[0]&0x70 != 0 || header[1]&0x80 == 0 {
+		// reserved bits without extension, or unmasked client frame
+		return false, 0, nil, errWebSocketProtocol
+	}
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if opcode >= wsClose && (length > 125 || !fin) {
+		return false, 0, nil, errWebSocketProtocol
+	}
+	if length > wsMaxMessageSize {
+		return false, 0, nil, errWebSocketTooLarge
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+// writeClose send a close frame with status code
+func (c *wsConn) writeClose(code uint16) error {
+	payload := make([]byte, 2)
+	binary.BigEndian.PutUint16(payload, code)
+	return c.writeFrame(wsClose, payload)
+}
@@ -0,0 +1,248 @@
+package sse
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// dialWebSocket minimal client used by tests
+func dialWebSocket(t *testing.T, url string) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+	if err != nil {
+		t.Fatalf("dial error = %v", err)
+	}
+	_, _ = io.WriteString(conn, "GET /ws HTTP/1.1\r\nHost: test\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n"+
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n")
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatalf("handshake error = %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("handshake status = %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("Sec-WebSocket-Accept = %q", got)
+	}
+	return conn, br
+}
+
+func writeClientFrame(conn net.Conn, fin bool, opcode byte, payload []byte) {
+	mask := []byte{1, 2, 3, 4}
+	header := []byte{opcode, 0x80}
+	if fin {
+		header[0] |= 0x80
+	}
+	if len(payload) < 126 {
+		header[1] |= byte(len(payload))
+	} else {
+		header[1] |= 126
+		header = append(header, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(len(payload)))
+	}
+	masked := make([]byte, len(payload))
+	for i := range payload {
+		masked[i] = payload[i] ^ mask[i%4]
+	}
+	_, _ = conn.Write(append(append(header, mask...), masked...))
+}
+
+func readServerFrame(t *testing.T, br *bufio.Reader) (byte, []byte) {
+	t.Helper()
+	var header [2]byte
+	if _, err := io.ReadFull(br, header[:]); err != nil {
+		t.Fatalf("read frame error = %v", err)
+	}
+	length := int(header[1] & 0x7F)
+	if length == 126 {
+		var ext [2]byte
+		_, _ = io.ReadFull(br, ext[:])
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]byte, length)
+	_, _ = io.ReadFull(br, payload)
+	return header[0] & 0x0F, payload
+}
+
+func readServerMessage(t *testing.T, br *bufio.Reader) *Message {
+	t.Helper()
+	opcode, payload := readServerFrame(t, br)
+	if opcode != wsText {
+		t.Fatalf("opcode = %d, want text", opcode)
+	}
+	message := &Message{}
+	if err := json.Unmarshal(payload, message); err != nil {
+		t.Fatalf("frame %q is not json: %v", payload, err)
+	}
+	return message
+}
+
+func TestHub_RegisterWebSocket(t *testing.T) {
+	hub := NewHub(&mockLog{})
+	received := make(chan *Message, 1)
+	disconnected := make(chan string, 1)
+	hub.ReceiveFunc = func(clientID string, message *Message) {
+		received <- message
+	}
+	hub.DisconnectFunc = func(clientID string) {
+		disconnected <- clientID
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hub.RegisterWebSocket(w, r, "zone", func() string { return "ws-1" })
+	}))
+	defer server.Close()
+
+	conn, br := dialWebSocket(t, server.URL)
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+
+	if ping := readServerMessage(t, br); ping.Event != "ping" || ping.ID != "ws-1" {
+		t.Fatalf("first message = %+v, want ping", ping)
+	}
+	err := hub.SendMessage(Packet{Message: &Message{Event: "event", Data: strings.Repeat("x", 200)}, Zone: "zone", ClientID: "ws-1"})
+	if err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+	if got := readServerMessage(t, br); got.Event != "event" || len(got.Data) != 200 {
+		t.Fatalf("message = %+v", got)
+	}
+
+	// fragmented message with a ping in between
+	writeClientFrame(conn, false, wsText, []byte(`{"event":"reply",`))
+	writeClientFrame(conn, true, wsPing, []byte("hi"))
+	writeClientFrame(conn, true, wsContinuation, []byte(`"data":"pong"}`))
+	if opcode, payload := readServerFrame(t, br); opcode != wsPong || string(payload) != "hi" {
+		t.Fatalf("pong frame = %d %q", opcode, payload)
+	}
+	select {
+	case got := <-received:
+		if got.Event != "reply" || got.Data != "pong" {
+			t.Fatalf("ReceiveFunc message = %+v", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ReceiveFunc was not called")
+	}
+
+	writeClientFrame(conn, true, wsText, []byte("not json"))
+	writeClientFrame(conn, true, wsClose, []byte{0x03, 0xE8})
+	if opcode, _ := readServerFrame(t, br); opcode != wsClose {
+		t.Fatalf("opcode = %d, want close", opcode)
+	}
+	select {
+	case id := <-disconnected:
+		if id != "ws-1" {
+			t.Fatalf("DisconnectFunc id = %v", id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("DisconnectFunc was not called")
+	}
+}
+
+func TestHub_RegisterWebSocketProtocolError(t *testing.T) {
+	hub := NewHub(nil)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hub.RegisterWebSocket(w, r, "", nil)
+	}))
+	defer server.Close()
+
+	conn, br := dialWebSocket(t, server.URL)
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	readServerMessage(t, br)
+
+	// continuation without a started message
+	writeClientFrame(conn, true, wsContinuation, []byte("x"))
+	opcode, payload := readServerFrame(t, br)
+	if opcode != wsClose || binary.BigEndian.Uint16(payload) != 1002 {
+		t.Fatalf("close frame = %d %v, want 1002", opcode, payload)
+	}
+}
+
+func TestHub_RegisterWebSocketBadHandshake(t *testing.T) {
+	hub := NewHub(&mockLog{})
+	tests := []struct {
+		name   string
+		header map[string]string
+		want   int
+	}{
+		{name: "plain request", header: map[string]string{}, want: http.StatusBadRequest},
+		{
+			name:   "bad version",
+			header: map[string]string{"Connection": "keep-alive, Upgrade", "Upgrade": "websocket", "Sec-WebSocket-Version": "8"},
+			want:   http.StatusUpgradeRequired,
+		},
+		{
+			name:   "missing key",
+			header: map[string]string{"Connection": "Upgrade", "Upgrade": "websocket", "Sec-WebSocket-Version": "13"},
+			want:   http.StatusBadRequest,
+		},
+		{
+			name: "not hijackable",
+			header: map[string]string{"Connection": "Upgrade", "Upgrade": "websocket", "Sec-WebSocket-Version": "13",
+				"Sec-WebSocket-Key": "dGhlIHNhbXBsZSBub25jZQ=="},
+			want: http.StatusInternalServerError,
+		},
+		{
+			name: "cross origin",
+			header: map[string]string{"Connection": "Upgrade", "Upgrade": "websocket", "Sec-WebSocket-Version": "13",
+				"Sec-WebSocket-Key": "dGhlIHNhbXBsZSBub25jZQ==", "Origin": "https://evil.example"},
+			want: http.StatusForbidden,
+		},
+		{
+			name: "same origin",
+			header: map[string]string{"Connection": "Upgrade", "Upgrade": "websocket", "Sec-WebSocket-Version": "13",
+				"Sec-WebSocket-Key": "dGhlIHNhbXBsZSBub25jZQ==", "Origin": "http://example.com"},
+			want: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/ws", nil)
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			recorder := httptest.NewRecorder()
+			hub.RegisterWebSocket(recorder, req, "zone", nil)
+			if recorder.Code != tt.want {
+				t.Fatalf("status = %d, want %d", recorder.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllowOrigins(t *testing.T) {
+	check := AllowOrigins("https://app.example")
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{origin: "", want: true},
+		{origin: "http://example.com", want: true},
+		{origin: "https://APP.example", want: true},
+		{origin: "https://evil.example", want: false},
+		{origin: "://bad", want: false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/ws", nil)
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		if got := check(req); got != tt.want {
+			t.Errorf("AllowOrigins()(%q) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+	req := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	req.Header.Set("Origin", "https://evil.example")
+	if !AllowOrigins("*")(req) {
+		t.Error("AllowOrigins(*) rejected an origin")
+	}
+}